@@ -0,0 +1,100 @@
+package v1beta1
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ValidateError checks that err, returned by the API server for a rejected request, meets the expected criteria.
+func (e *ExpectedError) ValidateError(err error) error {
+	var errs []string
+
+	var status k8serrors.APIStatus
+	if !errors.As(err, &status) {
+		// errors not returned by the API server only have a message
+		if e.Reason != "" || e.Field != "" {
+			return fmt.Errorf("expected an error returned by the API server, got: %v", err)
+		}
+		if e.Message != nil {
+			return e.Message.validateOutput("error message", err.Error())
+		}
+		return nil
+	}
+
+	if e.Reason != "" {
+		if reason := k8serrors.ReasonForError(err); reason != e.Reason {
+			errs = append(errs, fmt.Sprintf("expected reason %s, got: %s", e.Reason, reason))
+		}
+	}
+	if e.Message != nil {
+		if err := e.Message.validateOutput("error message", status.Status().Message); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if e.Field != "" {
+		fields := []string{}
+		matched := false
+		if details := status.Status().Details; details != nil {
+			for _, cause := range details.Causes {
+				field := strings.TrimPrefix(cause.Field, ".")
+				if field == "" {
+					continue
+				}
+				fields = append(fields, field)
+				matched = matched || field == strings.TrimPrefix(e.Field, ".")
+			}
+		}
+		if !matched {
+			errs = append(errs, fmt.Sprintf("expected an error for field %s, got errors for fields: %v", e.Field, fields))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("unexpected error %q: %s", err, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ValidateWarnings checks that the warnings returned by the API server meet the expected criteria.
+func (w *Warnings) ValidateWarnings(warnings []string) error {
+	var errs []string
+
+	for _, expected := range w.Expected {
+		expected := expected
+		if matchesAny(&expected, warnings) == "" {
+			errs = append(errs, fmt.Sprintf("expected a warning matching %s", describeOutput(&expected)))
+		}
+	}
+	for _, forbidden := range w.Forbidden {
+		forbidden := forbidden
+		if warning := matchesAny(&forbidden, warnings); warning != "" {
+			errs = append(errs, fmt.Sprintf("unexpected warning matching %s: %s", describeOutput(&forbidden), warning))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("unexpected warnings %q: %s", warnings, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// matchesAny returns the first of warnings matching e, or an empty string if none does.
+func matchesAny(e *ExpectedOutput, warnings []string) string {
+	for _, warning := range warnings {
+		if e.validateOutput("warning", warning) == nil {
+			return warning
+		}
+	}
+	return ""
+}
+
+func describeOutput(e *ExpectedOutput) string {
+	matchType := e.MatchType
+	if matchType == "" {
+		matchType = MatchEquals
+	}
+	return fmt.Sprintf("%s %q", matchType, e.ExpectedValue)
+}
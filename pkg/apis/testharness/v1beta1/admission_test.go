@@ -0,0 +1,155 @@
+package v1beta1
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateError(t *testing.T) {
+	invalid := k8serrors.NewInvalid(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "hello", field.ErrorList{
+		field.Invalid(field.NewPath("spec", "replicas"), -1, "must be greater than or equal to 0"),
+	})
+	denied := &k8serrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    403,
+		Reason:  metav1.StatusReasonForbidden,
+		Message: `admission webhook "validate.kyverno.svc-fail" denied the request: policy Pod/world/hello for resource violation: require-labels`,
+	}}
+
+	tests := []struct {
+		name        string
+		expected    ExpectedError
+		err         error
+		expectedErr string
+	}{
+		{
+			name:     "reason and field match",
+			expected: ExpectedError{Reason: metav1.StatusReasonInvalid, Field: "spec.replicas"},
+			err:      invalid,
+		},
+		{
+			name:     "wrapped error",
+			expected: ExpectedError{Reason: metav1.StatusReasonInvalid, Field: ".spec.replicas"},
+			err:      fmt.Errorf("patching Deployment:world/hello: %w", invalid),
+		},
+		{
+			name:        "reason does not match",
+			expected:    ExpectedError{Reason: metav1.StatusReasonForbidden},
+			err:         invalid,
+			expectedErr: fmt.Sprintf("unexpected error %q: expected reason Forbidden, got: Invalid", invalid),
+		},
+		{
+			name:        "field does not match",
+			expected:    ExpectedError{Field: "spec.template"},
+			err:         invalid,
+			expectedErr: fmt.Sprintf("unexpected error %q: expected an error for field spec.template, got errors for fields: [spec.replicas]", invalid),
+		},
+		{
+			name: "webhook message contains",
+			expected: ExpectedError{Reason: metav1.StatusReasonForbidden, Message: &ExpectedOutput{
+				MatchType:     MatchContains,
+				ExpectedValue: `admission webhook "validate.kyverno.svc-fail" denied the request`,
+			}},
+			err: denied,
+		},
+		{
+			name: "webhook message regex",
+			expected: ExpectedError{Message: &ExpectedOutput{
+				MatchType:     MatchRegex,
+				ExpectedValue: `^admission webhook "validate\.kyverno\.[a-z-]+" denied the request: .*require-labels$`,
+			}},
+			err: denied,
+		},
+		{
+			name: "webhook message does not equal",
+			expected: ExpectedError{Message: &ExpectedOutput{
+				ExpectedValue: "denied",
+			}},
+			err:         denied,
+			expectedErr: fmt.Sprintf("unexpected error %q: expected exact error message: denied, got: %s", denied, denied.ErrStatus.Message),
+		},
+		{
+			name: "message of other errors",
+			expected: ExpectedError{Message: &ExpectedOutput{
+				MatchType:     MatchContains,
+				ExpectedValue: "timeout",
+			}},
+			err: errors.New("create/update timeout exceeded"),
+		},
+		{
+			name:        "reason of other errors",
+			expected:    ExpectedError{Reason: metav1.StatusReasonInvalid},
+			err:         errors.New("create/update timeout exceeded"),
+			expectedErr: "expected an error returned by the API server, got: create/update timeout exceeded",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.expected.ValidateError(tt.err)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateWarnings(t *testing.T) {
+	warnings := []string{
+		"spec.template.spec.containers[0].image: use of the latest tag is discouraged",
+		"policy/v1beta1 PodSecurityPolicy is deprecated in v1.21+, unavailable in v1.25+",
+	}
+
+	tests := []struct {
+		name        string
+		expected    Warnings
+		expectedErr string
+	}{
+		{
+			name: "expected warnings",
+			expected: Warnings{Expected: []ExpectedOutput{
+				{MatchType: MatchContains, ExpectedValue: "latest tag"},
+				{MatchType: MatchRegex, ExpectedValue: `PodSecurityPolicy is deprecated`},
+			}},
+		},
+		{
+			name:     "forbidden warning not returned",
+			expected: Warnings{Forbidden: []ExpectedOutput{{MatchType: MatchWildcard, ExpectedValue: "*unknown field*"}}},
+		},
+		{
+			name:        "missing warning",
+			expected:    Warnings{Expected: []ExpectedOutput{{ExpectedValue: "latest tag"}}},
+			expectedErr: fmt.Sprintf(`unexpected warnings %q: expected a warning matching Equals "latest tag"`, warnings),
+		},
+		{
+			name: "forbidden warning",
+			expected: Warnings{
+				Expected:  []ExpectedOutput{{MatchType: MatchContains, ExpectedValue: "missing"}},
+				Forbidden: []ExpectedOutput{{MatchType: MatchContains, ExpectedValue: "deprecated"}},
+			},
+			expectedErr: fmt.Sprintf(`unexpected warnings %q: expected a warning matching Contains "missing"; unexpected warning matching Contains "deprecated": %s`, warnings, warnings[1]),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.expected.ValidateWarnings(warnings)
+			if tt.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expectedErr)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package v1beta1
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/IGLOU-EU/go-wildcard"
@@ -43,6 +44,15 @@ func (e *ExpectedOutput) validateOutput(outputType string, actualValue string) e
 			return fmt.Errorf("%s did not match wildcard pattern: %s", outputType, expectedValue)
 		}
 
+	case MatchRegex:
+		re, err := regexp.Compile(expectedValue)
+		if err != nil {
+			return fmt.Errorf("invalid regular expression %s: %w", expectedValue, err)
+		}
+		if !re.MatchString(actualValue) {
+			return fmt.Errorf("%s did not match regular expression: %s", outputType, expectedValue)
+		}
+
 	case MatchEquals:
 		if actualValue != expectedValue {
 			return fmt.Errorf("expected exact %s: %s, got: %s", outputType, expectedValue, actualValue)
@@ -84,6 +84,36 @@ func TestValidateCommandOutput(t *testing.T) {
 			}(),
 			wantErr: false,
 		},
+		{
+			name: "stdout regex match",
+			cmdOutput: CommandOutput{
+				Stdout: &ExpectedOutput{
+					MatchType:     MatchRegex,
+					ExpectedValue: "^Hello, [A-Z][a-z]+!$",
+				},
+			},
+			stdoutOutput: func() strings.Builder {
+				b := strings.Builder{}
+				b.WriteString("Hello, World!")
+				return b
+			}(),
+			wantErr: false,
+		},
+		{
+			name: "stdout regex does not match",
+			cmdOutput: CommandOutput{
+				Stdout: &ExpectedOutput{
+					MatchType:     MatchRegex,
+					ExpectedValue: "^Hello, [0-9]+!$",
+				},
+			},
+			stdoutOutput: func() strings.Builder {
+				b := strings.Builder{}
+				b.WriteString("Hello, World!")
+				return b
+			}(),
+			wantErr: true,
+		},
 		{
 			name: "stdout matches but stderr fails",
 			cmdOutput: CommandOutput{
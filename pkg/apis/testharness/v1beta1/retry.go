@@ -0,0 +1,62 @@
+package v1beta1
+
+import (
+	"strings"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+)
+
+const (
+	defaultRetryBackoff = time.Second
+	maxRetryBackoff     = 10 * time.Second
+)
+
+// RetryReason returns why err should be retried according to the error classes of the policy, or an empty string if
+// it should not be. The message patterns are compiled and matched by the harness.
+func (p *RetryPolicy) RetryReason(err error) string {
+	if p == nil || err == nil {
+		return ""
+	}
+
+	for _, class := range p.Errors {
+		if isRetryable(class, err) {
+			return string(class)
+		}
+	}
+	return ""
+}
+
+// RetryBackoff returns the delay before the retry following the given number of retries. Only the doubled delays
+// are capped, a configured backoff longer than the cap is used as is.
+func (p *RetryPolicy) RetryBackoff(retries int) time.Duration {
+	backoff := defaultRetryBackoff
+	if p != nil && p.Backoff != nil {
+		backoff = p.Backoff.Duration
+	}
+	if backoff >= maxRetryBackoff {
+		return backoff
+	}
+	for i := 0; i < retries && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+	return backoff
+}
+
+func isRetryable(class RetryableError, err error) bool {
+	switch class {
+	case RetryWebhookUnavailable:
+		message := err.Error()
+		return strings.Contains(message, "failed calling webhook") || strings.Contains(message, "no endpoints available for service")
+	case RetryServerUnavailable:
+		return k8serrors.IsServerTimeout(err) || k8serrors.IsTimeout(err) || k8serrors.IsTooManyRequests(err) ||
+			k8serrors.IsServiceUnavailable(err) || utilnet.IsConnectionRefused(err)
+	case RetryConflict:
+		return k8serrors.IsConflict(err)
+	}
+	return false
+}
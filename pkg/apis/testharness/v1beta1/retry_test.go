@@ -0,0 +1,81 @@
+package v1beta1
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRetryReason(t *testing.T) {
+	webhook := k8serrors.NewInternalError(errors.New(`failed calling webhook "mutate.kyverno.svc-fail": failed to call webhook: Post "https://kyverno-svc.kyverno.svc:443/mutate?timeout=10s": no endpoints available for service "kyverno-svc"`))
+	conflict := k8serrors.NewConflict(schema.GroupResource{Resource: "pods"}, "hello", errors.New("the object has been modified"))
+
+	tests := []struct {
+		name     string
+		policy   *RetryPolicy
+		err      error
+		expected string
+	}{
+		{
+			name:   "no policy",
+			policy: nil,
+			err:    webhook,
+		},
+		{
+			name:   "no error",
+			policy: &RetryPolicy{Errors: []RetryableError{RetryWebhookUnavailable}},
+		},
+		{
+			name:     "unavailable webhook",
+			policy:   &RetryPolicy{Errors: []RetryableError{RetryConflict, RetryWebhookUnavailable}},
+			err:      fmt.Errorf("creating Pod:world/hello: %w", webhook),
+			expected: "WebhookUnavailable",
+		},
+		{
+			name:     "unavailable server",
+			policy:   &RetryPolicy{Errors: []RetryableError{RetryServerUnavailable}},
+			err:      k8serrors.NewServiceUnavailable("the server is currently unable to handle the request"),
+			expected: "ServerUnavailable",
+		},
+		{
+			name:     "conflict",
+			policy:   &RetryPolicy{Errors: []RetryableError{RetryConflict}},
+			err:      conflict,
+			expected: "Conflict",
+		},
+		{
+			name:   "error of another class",
+			policy: &RetryPolicy{Errors: []RetryableError{RetryWebhookUnavailable, RetryServerUnavailable}},
+			err:    conflict,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.policy.RetryReason(test.err))
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	var policy *RetryPolicy
+	assert.Equal(t, time.Second, policy.RetryBackoff(0))
+	assert.Equal(t, 4*time.Second, policy.RetryBackoff(2))
+
+	policy = &RetryPolicy{Backoff: &metav1.Duration{Duration: 500 * time.Millisecond}}
+	assert.Equal(t, 500*time.Millisecond, policy.RetryBackoff(0))
+	assert.Equal(t, time.Second, policy.RetryBackoff(1))
+	assert.Equal(t, 10*time.Second, policy.RetryBackoff(5))
+	assert.Equal(t, 10*time.Second, policy.RetryBackoff(100))
+
+	policy = &RetryPolicy{Backoff: &metav1.Duration{Duration: 30 * time.Second}}
+	assert.Equal(t, 30*time.Second, policy.RetryBackoff(0))
+	assert.Equal(t, 30*time.Second, policy.RetryBackoff(3))
+}
@@ -14,6 +14,7 @@ const (
 	MatchEquals   MatchType = "Equals"
 	MatchContains MatchType = "Contains"
 	MatchWildcard MatchType = "Wildcard"
+	MatchRegex    MatchType = "Regex"
 )
 
 type Strategy string
@@ -23,6 +24,14 @@ const (
 	StrategyExact    Strategy = "Exact"
 )
 
+type PatchType string
+
+const (
+	PatchJSON      PatchType = "JSON"
+	PatchMerge     PatchType = "Merge"
+	PatchStrategic PatchType = "Strategic"
+)
+
 // Create embedded struct to implement custom DeepCopyInto method
 type RestConfig struct {
 	RC *rest.Config `json:"-"`
@@ -97,13 +106,80 @@ type TestSuite struct {
 	// SkipTestRegex is used to skip tests based on a regular expression.
 	SkipTestRegex string `json:"skipTestRegex"`
 
+	// Variables are available to the commands of all test steps. They are expanded as $NAME or ${NAME}
+	// in the objects to apply, assert and error of all test steps.
+	Variables map[string]string `json:"variables,omitempty"`
+	// Templating configures the substitution of variables in the objects of all test steps.
+	Templating *Templating `json:"templating,omitempty"`
+	// ServerSideApply applies the objects of all test steps with server-side apply instead of a merge patch.
+	ServerSideApply *ServerSideApply `json:"serverSideApply,omitempty"`
+	// Retry configures the retries of the applies and deletions of all test steps failing with transient errors.
+	Retry *RetryPolicy `json:"retry,omitempty"`
+
 	Config *RestConfig `json:"config,omitempty"`
 }
 
+// Templating configures the substitution of variables in the objects to apply, assert and error.
+type Templating struct {
+	// Enabled substitutes $NAMESPACE and OS environment variables in addition to the suite and captured
+	// variables, and replaces $$ by $.
+	Enabled bool `json:"enabled"`
+	// Strict fails the test step if an object references an undefined variable.
+	Strict bool `json:"strict,omitempty"`
+}
+
+// ServerSideApply configures how objects are applied with server-side apply.
+type ServerSideApply struct {
+	// Enabled applies objects with server-side apply, fields removed from a manifest are then removed
+	// from the object if no other field manager owns them.
+	Enabled bool `json:"enabled"`
+	// FieldManager is the name of the field manager applying the objects, it defaults to kuttl.
+	FieldManager string `json:"fieldManager,omitempty"`
+	// ForceConflicts takes the ownership of fields owned by other field managers instead of failing.
+	ForceConflicts bool `json:"forceConflicts,omitempty"`
+}
+
+// RetryPolicy configures the retries of the requests failing with transient errors, e.g. while the admission
+// webhooks of a controller which just started are not available yet.
+type RetryPolicy struct {
+	// Errors are the classes of errors to retry, among WebhookUnavailable (an admission webhook could not be
+	// called), ServerUnavailable (the API server timed out, throttled the request or was unavailable) and Conflict.
+	Errors []RetryableError `json:"errors,omitempty"`
+	// Messages are regular expressions, the errors whose message matches any of them are retried.
+	Messages []string `json:"messages,omitempty"`
+	// Backoff is the delay before the first retry (e.g. 500ms), it doubles after each retry up to 10s. A backoff
+	// longer than 10s is not doubled. Defaults to 1s.
+	Backoff *metav1.Duration `json:"backoff,omitempty"`
+	// MaxDuration stops the retries once the duration elapsed since the first attempt (e.g. 1m). The retries
+	// stop at the timeout of the test step in any case.
+	MaxDuration *metav1.Duration `json:"maxDuration,omitempty"`
+}
+
+// RetryableError is a class of transient errors.
+type RetryableError string
+
+const (
+	// RetryWebhookUnavailable retries the requests an admission webhook could not be called for, e.g. because
+	// its service has no endpoints yet.
+	RetryWebhookUnavailable RetryableError = "WebhookUnavailable"
+	// RetryServerUnavailable retries the requests the API server timed out, throttled or was unavailable for.
+	RetryServerUnavailable RetryableError = "ServerUnavailable"
+	// RetryConflict retries the requests which conflicted with a concurrent update.
+	RetryConflict RetryableError = "Conflict"
+)
+
 // Apply holds infos for an apply statement
 type Apply struct {
 	File       string `json:"file,omitempty"`
 	ShouldFail bool   `json:"shouldFail,omitempty"`
+	// ServerSideApply overrides the server-side apply configuration of the test step for this file.
+	ServerSideApply *ServerSideApply `json:"serverSideApply,omitempty"`
+	// ExpectedError defines the criteria the error of the apply should meet, it implies ShouldFail.
+	ExpectedError *ExpectedError `json:"expectedError,omitempty"`
+	// Warnings defines the criteria the warnings returned by the API server for each object of the file should meet.
+	Warnings *Warnings `json:"warnings,omitempty"`
+	// WaitForReady overrides the readiness wait of the test step for the objects of this file.
+	WaitForReady *bool `json:"waitForReady,omitempty"`
 }
 
 // UnmarshalJSON implements the json.Unmarshaller interface.
@@ -113,17 +189,66 @@ func (apply *Apply) UnmarshalJSON(value []byte) error {
 		return json.Unmarshal(value, &apply.File)
 	}
 	data := struct {
-		File       string `json:"file,omitempty"`
-		ShouldFail bool   `json:"shouldFail,omitempty"`
+		File            string           `json:"file,omitempty"`
+		ShouldFail      bool             `json:"shouldFail,omitempty"`
+		ServerSideApply *ServerSideApply `json:"serverSideApply,omitempty"`
+		ExpectedError   *ExpectedError   `json:"expectedError,omitempty"`
+		Warnings        *Warnings        `json:"warnings,omitempty"`
+		WaitForReady    *bool            `json:"waitForReady,omitempty"`
 	}{}
 	if err := json.Unmarshal(value, &data); err != nil {
 		return err
 	}
 	apply.File = data.File
 	apply.ShouldFail = data.ShouldFail
+	apply.ServerSideApply = data.ServerSideApply
+	apply.ExpectedError = data.ExpectedError
+	apply.Warnings = data.Warnings
+	apply.WaitForReady = data.WaitForReady
 	return nil
 }
 
+// Patch holds infos for a patch statement
+type Patch struct {
+	// Object references the object to patch, its name is required.
+	Object ObjectReference `json:"object"`
+	// Type of the patch, one of JSON (RFC 6902), Merge (RFC 7386) or Strategic (default).
+	Type PatchType `json:"type,omitempty"`
+	// Patch is the body of the patch in YAML or JSON, exclusive with File.
+	Patch string `json:"patch,omitempty"`
+	// File containing the body of the patch, relative to the folder the TestStep is defined in.
+	File string `json:"file,omitempty"`
+	// ShouldFail expects the patch to be rejected.
+	ShouldFail bool `json:"shouldFail,omitempty"`
+	// ExpectedError defines the criteria the error of the patch should meet, it implies ShouldFail.
+	ExpectedError *ExpectedError `json:"expectedError,omitempty"`
+}
+
+// Replace holds infos for a replace statement
+type Replace struct {
+	// File containing the objects replacing the existing objects.
+	File string `json:"file"`
+	// ShouldFail expects the replace to be rejected.
+	ShouldFail bool `json:"shouldFail,omitempty"`
+	// ExpectedError defines the criteria the error of the replace should meet, it implies ShouldFail.
+	ExpectedError *ExpectedError `json:"expectedError,omitempty"`
+}
+
+// DryRun holds infos for a dry-run statement
+type DryRun struct {
+	// File containing the objects to submit with server-side dry-run, they are admitted but not persisted.
+	File string `json:"file"`
+	// Expected is a file containing the objects expected to be returned by the API server, e.g. once mutated by
+	// admission webhooks. They are compared with the returned objects like the objects of an assert file.
+	Expected string `json:"expected,omitempty"`
+	// ShouldFail expects the objects to be denied.
+	ShouldFail bool `json:"shouldFail,omitempty"`
+	// ExpectedError defines the criteria the error of the dry-run should meet, it implies ShouldFail.
+	ExpectedError *ExpectedError `json:"expectedError,omitempty"`
+	// Warnings defines the criteria the warnings returned by the API server for each object should meet.
+	Warnings *Warnings `json:"warnings,omitempty"`
+}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // TestStep settings to apply to a test step.go
@@ -141,10 +266,17 @@ type TestStep struct {
 	// all relative paths are relative to the folder the TestStep is defined in.
 	Apply  []Apply  `json:"apply,omitempty"`
 	Assert []Assert `json:"assert,omitempty"`
-	Error  []string `json:"error,omitempty"`
+	Error  []Error  `json:"error,omitempty"`
 
 	// Objects to delete at the beginning of the test step.
-	Delete []ObjectReference `json:"delete,omitempty"`
+	Delete []Deletion `json:"delete,omitempty"`
+
+	// Objects to patch once the objects of the test step are applied.
+	Patch []Patch `json:"patch,omitempty"`
+	// Objects replacing the existing objects (PUT) once the objects of the test step are applied and patched.
+	Replace []Replace `json:"replace,omitempty"`
+	// Objects to submit with server-side dry-run once the objects of the test step are applied, patched and replaced.
+	DryRun []DryRun `json:"dryRun,omitempty"`
 
 	// Indicates that this is a unit test - safe to run without a real Kubernetes cluster.
 	UnitTest bool `json:"unitTest"`
@@ -152,11 +284,57 @@ type TestStep struct {
 	// Commands to run prior at the beginning of the test step.
 	Commands []Command `json:"commands"`
 
+	// Finally commands run once the steps of the test case are done, whether they succeeded or not, e.g. to
+	// uninstall what the step installed or to dump the state of the cluster. The finally commands of the steps which
+	// were started run in reverse order, before the namespace of the test case is deleted. They must not run in the
+	// background.
+	Finally []Command `json:"finally,omitempty"`
+
 	// Allowed environment labels
 	// Disallowed environment labels
 
 	// Kubeconfig to use when applying and asserting for this step.
 	Kubeconfig string `json:"kubeconfig,omitempty"`
+
+	// Impersonate a user or service account when applying, asserting and running the commands of this step.
+	Impersonate *Impersonation `json:"impersonate,omitempty"`
+
+	// Capture values of objects once the objects of the step are applied.
+	Capture []Capture `json:"capture,omitempty"`
+
+	// Templating overrides the templating configuration of the test suite for this step.
+	Templating *Templating `json:"templating,omitempty"`
+
+	// ServerSideApply overrides the server-side apply configuration of the test suite for this step.
+	ServerSideApply *ServerSideApply `json:"serverSideApply,omitempty"`
+
+	// Warnings defines the criteria the warnings returned by the API server while applying all the objects of
+	// the step should meet.
+	Warnings *Warnings `json:"warnings,omitempty"`
+
+	// WaitForReady waits until the applied objects are ready before asserting, or until the timeout of the step
+	// expires. Workloads must be rolled out, jobs completed and the Ready condition of other objects, if any, true.
+	WaitForReady bool `json:"waitForReady,omitempty"`
+
+	// PreserveOrder applies the objects of the step in the order of the files, only Namespaces and
+	// CustomResourceDefinitions are applied first. By default the objects are applied by kind, in the order
+	// Namespaces, CustomResourceDefinitions, admission policies, service accounts and RBAC, configuration and storage,
+	// workloads, then all other objects such as custom resources.
+	PreserveOrder bool `json:"preserveOrder,omitempty"`
+
+	// Retry overrides the retry policy of the test suite for this step.
+	Retry *RetryPolicy `json:"retry,omitempty"`
+}
+
+// Impersonation defines the subject a test step acts as.
+type Impersonation struct {
+	// User to impersonate, exclusive with ServiceAccount.
+	User string `json:"user,omitempty"`
+	// Groups to impersonate in addition to the groups of the user or service account.
+	Groups []string `json:"groups,omitempty"`
+	// ServiceAccount to impersonate, either the name of a service account in the namespace of the test or
+	// namespace/name. Exclusive with User.
+	ServiceAccount string `json:"serviceAccount,omitempty"`
 }
 
 type Assert struct {
@@ -167,6 +345,25 @@ type Assert struct {
 
 type Options struct {
 	AssertArray []AssertArray `json:"arrays,omitempty"`
+	// Count constrains the number of objects matching the assert document.
+	Count *Count `json:"count,omitempty"`
+	// LabelSelector restricts the objects compared against a document without a name to those matching
+	// the label selector (e.g. app=nginx,tier!=frontend). The labels of the document are always added.
+	LabelSelector string `json:"labelSelector,omitempty"`
+	// FieldSelector restricts the objects compared against a document without a name to those matching
+	// the field selector (e.g. status.phase=Running).
+	FieldSelector string `json:"fieldSelector,omitempty"`
+}
+
+// Count specifies how many objects must match an assert document. Without a count, an assert
+// document without a name passes if any object of its kind matches.
+type Count struct {
+	// Exact is the exact number of objects which must match.
+	Exact *int `json:"exact,omitempty"`
+	// Min is the minimum number of objects which must match.
+	Min *int `json:"min,omitempty"`
+	// Max is the maximum number of objects which may match.
+	Max *int `json:"max,omitempty"`
 }
 
 // AssertArray specifies conditions for verifying content within a YAML against a Kubernetes resource.
@@ -195,6 +392,31 @@ func (assert *Assert) UnmarshalJSON(value []byte) error {
 	return nil
 }
 
+// Error holds infos for an errors file of a test step.
+type Error struct {
+	// File specifies the relative or full path to the YAML containing the objects which must not exist.
+	File string `json:"file"`
+	// Options for the errors file. A count is not supported.
+	Options *Options `json:"options,omitempty"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaller interface.
+func (e *Error) UnmarshalJSON(value []byte) error {
+	if value[0] == '"' {
+		return json.Unmarshal(value, &e.File)
+	}
+	data := struct {
+		File    string   `json:"file,omitempty"`
+		Options *Options `json:"options,omitempty"`
+	}{}
+	if err := json.Unmarshal(value, &data); err != nil {
+		return err
+	}
+	e.File = data.File
+	e.Options = data.Options
+	return nil
+}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // TestAssert represents the settings needed to verify the result of a test step.
@@ -205,10 +427,82 @@ type TestAssert struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 	// Override the default timeout of 30 seconds (in seconds).
 	Timeout int `json:"timeout"`
+	// HoldFor requires the asserted state to hold continuously for the given duration (e.g. 30s) before the
+	// test step passes. Objects of the errors files must stay absent for the same duration.
+	HoldFor *metav1.Duration `json:"holdFor,omitempty"`
+	// ErrorsInvariant fails the test step as soon as an object matches an error assertion at any time during the
+	// step, including objects which only match between two checks. Objects which existed unchanged before the step
+	// started are not considered. Set it to false to re-check the error assertions until the timeout of the step
+	// instead, so that a state which clears up eventually passes. Defaults to true.
+	ErrorsInvariant *bool `json:"errorsInvariant,omitempty"`
 	// Collectors is a set of pod log collectors fired on an assert failure
 	Collectors []*TestCollector `json:"collectors,omitempty"`
 	// Commands is a set of commands to be run as assertions for the current step
 	Commands []TestAssertCommand `json:"commands,omitempty"`
+	// Capture values of objects once the assertions of the step passed.
+	Capture []Capture `json:"capture,omitempty"`
+	// Access checks whether subjects are allowed to perform actions, like kubectl auth can-i.
+	Access []AccessCheck `json:"access,omitempty"`
+	// Absent references objects which must be gone, the step waits until they are not found. Objects referenced
+	// by labels instead of a name must all be gone.
+	Absent []ObjectReference `json:"absent,omitempty"`
+	// Ownership checks the owner references of objects.
+	Ownership []OwnershipCheck `json:"ownership,omitempty"`
+}
+
+// OwnershipCheck asserts that objects are owned by an owner, so that they are garbage collected when it is deleted.
+// The owner references must match the uid of the owner, and namespaced owners must be in the namespace of the
+// dependents, for the deletion of the owner to cascade.
+type OwnershipCheck struct {
+	// Owner references the owner by name, or by labels which must match exactly one object.
+	Owner ObjectReference `json:"owner"`
+	// Dependents reference the objects which must be owned by the owner. Objects referenced by labels instead of a
+	// name must all be owned, and at least one must match.
+	Dependents []ObjectReference `json:"dependents"`
+	// Controller requires the owner to be the controller of the dependents.
+	Controller bool `json:"controller,omitempty"`
+	// BlockOwnerDeletion requires the owner references to block the foreground deletion of the owner until the
+	// dependents are deleted.
+	BlockOwnerDeletion bool `json:"blockOwnerDeletion,omitempty"`
+	// Cascade deletes the owner once all assertions of the test step passed, and waits until the garbage collector
+	// deleted the dependents, until the timeout of the step.
+	Cascade bool `json:"cascade,omitempty"`
+}
+
+// AccessCheck asserts whether a subject is allowed to perform an action on a resource.
+type AccessCheck struct {
+	// Subject to check the access of with a SubjectAccessReview. If not set, the access of the subject of the
+	// test step is checked with a SelfSubjectAccessReview, e.g. of the subject it impersonates.
+	Subject *Impersonation `json:"subject,omitempty"`
+	// Verb to check, e.g. get, list, create, update, delete or watch.
+	Verb string `json:"verb"`
+	// Group of the resource, empty for the core API group.
+	Group string `json:"group,omitempty"`
+	// Resource to check, e.g. pods or deployments.
+	Resource string `json:"resource"`
+	// Subresource to check, e.g. log or status.
+	Subresource string `json:"subresource,omitempty"`
+	// Name of the object to check, all objects if not set.
+	Name string `json:"name,omitempty"`
+	// Namespace to check, it defaults to the test namespace.
+	Namespace string `json:"namespace,omitempty"`
+	// AllNamespaces checks the access in all namespaces, it must be set for cluster-scoped resources.
+	AllNamespaces bool `json:"allNamespaces,omitempty"`
+	// Allowed is whether the subject is expected to be allowed or denied.
+	Allowed bool `json:"allowed"`
+}
+
+// Capture stores a value of a live object in a variable. The variables are available to the following test steps
+// as $NAME or ${NAME} in the objects to apply, assert and delete, and in the environment of commands.
+type Capture struct {
+	// Variable is the name of the variable to store the value in.
+	Variable string `json:"variable"`
+	// Object references the object to capture the value from. If no name is set, the labels must match exactly
+	// one object. The namespace defaults to the test namespace.
+	Object ObjectReference `json:"object"`
+	// Path is a JMESPath expression evaluated against the object, e.g. metadata.name or spec.clusterIP.
+	// Values which are not strings are stored as JSON.
+	Path string `json:"path"`
 }
 
 // TestAssertCommand an assertion based on the result of the execution of a command
@@ -233,6 +527,27 @@ type ObjectReference struct {
 	Labels map[string]string `json:"labels"`
 }
 
+// Deletion references objects to delete and configures how they are deleted.
+type Deletion struct {
+	ObjectReference `json:",inline"`
+	// LabelSelector selects the objects to delete if no name is set (e.g. app=nginx,tier!=frontend), in addition
+	// to the labels.
+	LabelSelector string `json:"labelSelector,omitempty"`
+	// FieldSelector selects the objects to delete if no name is set (e.g. status.phase=Succeeded).
+	FieldSelector string `json:"fieldSelector,omitempty"`
+	// PropagationPolicy determines whether and how the dependents of the objects are deleted, one of Foreground,
+	// Background or Orphan. The default policy of the kind applies if not set.
+	PropagationPolicy *metav1.DeletionPropagation `json:"propagationPolicy,omitempty"`
+	// GracePeriodSeconds overrides the grace period of the objects, 0 deletes them immediately.
+	// +kubebuilder:validation:Format:=int64
+	GracePeriodSeconds *int64 `json:"gracePeriodSeconds,omitempty"`
+	// Wait blocks until the objects are gone, or until the timeout of the step expires. Defaults to true.
+	Wait *bool `json:"wait,omitempty"`
+	// WaitForDependents also blocks until the objects owned by the deleted objects, directly or not, are gone.
+	// The dependents are looked up in the namespace of the test and in the namespaces of the deleted objects.
+	WaitForDependents bool `json:"waitForDependents,omitempty"`
+}
+
 // Command describes a command to run as a part of a test step or suite.
 type Command struct {
 	// The command and argument to run as a string.
@@ -267,12 +582,31 @@ type CommandOutput struct {
 // ExpectedOutput defines the criteria that command output should meet.
 type ExpectedOutput struct {
 	// MatchType is the type of match that should be applied for validation.
-	// This could be "Equals", "Contains", or "Wildcard".
+	// This could be "Equals", "Contains", "Wildcard" or "Regex".
 	MatchType MatchType `json:"match"`
 	// Value is the expected value or pattern that should be matched against the command's output.
 	ExpectedValue string `json:"expected"`
 }
 
+// ExpectedError defines the criteria that the error of a request rejected by the API server should meet.
+type ExpectedError struct {
+	// Reason is the expected reason of the status returned by the API server, e.g. Invalid, Forbidden or Conflict.
+	Reason metav1.StatusReason `json:"reason,omitempty"`
+	// Message contains the expected criteria for the message of the status, e.g. the message of the admission
+	// webhook or of the validation rule rejecting the request.
+	Message *ExpectedOutput `json:"message,omitempty"`
+	// Field is the path of a field causing the error, e.g. spec.replicas.
+	Field string `json:"field,omitempty"`
+}
+
+// Warnings defines the criteria that the warnings returned by the API server should meet.
+type Warnings struct {
+	// Expected contains the criteria that must each be met by at least one warning.
+	Expected []ExpectedOutput `json:"expected,omitempty"`
+	// Forbidden contains the criteria that must not be met by any warning.
+	Forbidden []ExpectedOutput `json:"forbidden,omitempty"`
+}
+
 // TestCollector are post assert / error commands that allow for the collection of information sent to the test log.
 // Type can be pod, command or event.  For backward compatibility, pod is default and doesn't need to be specified
 // For pod, At least one of `pod` or `selector` is required.
@@ -1,13 +1,44 @@
 package env
 
 import (
+	"fmt"
 	"os"
+	"regexp"
 	"strings"
 )
 
 // Expand provides OS expansion of defined ENV VARs inside args to commands.  The expansion is limited to what is defined on the OS
 // and the variables passed into to the env parameter. To escape a dollar sign, pass in two dollar signs.
 func ExpandWithMap(c string, env map[string]string) string {
+	fullEnv := fullEnv(env)
+
+	return os.Expand(c, func(s string) string {
+		return fullEnv[s]
+	})
+}
+
+// ExpandWithMapStrict provides the same expansion as ExpandWithMap, but returns an error if c references variables
+// which are neither defined on the OS nor in env.
+func ExpandWithMapStrict(c string, env map[string]string) (string, error) {
+	fullEnv := fullEnv(env)
+
+	undefined := []string{}
+	expanded := os.Expand(c, func(s string) string {
+		value, ok := fullEnv[s]
+		if !ok {
+			undefined = append(undefined, s)
+		}
+		return value
+	})
+
+	if len(undefined) > 0 {
+		return "", fmt.Errorf("undefined variables: %s", strings.Join(undefined, ", "))
+	}
+	return expanded, nil
+}
+
+// fullEnv returns the OS environment variables merged with env.
+func fullEnv(env map[string]string) map[string]string {
 	// expand $$ -> $
 	fullEnv := map[string]string{
 		"$": "$",
@@ -27,12 +58,33 @@ func ExpandWithMap(c string, env map[string]string) string {
 		fullEnv[k] = v
 	}
 
-	return os.Expand(c, func(s string) string {
-		return fullEnv[s]
-	})
+	return fullEnv
 }
 
 // Expand provides shell expansion similar to ExpandWithMap without the map extension.  It is os.Env only.
 func Expand(c string) string {
 	return ExpandWithMap(c, nil)
 }
+
+// variableRef matches references to variables in the form of $NAME or ${NAME}, and escaped dollar signs.
+var variableRef = regexp.MustCompile(`\$(?:\$|\{(\w+)\}|(\w+))`)
+
+// ExpandVariables expands the references to the variables defined in vars only. All other references, including
+// references to OS environment variables and $$, are left untouched so that arbitrary content can be expanded safely.
+func ExpandVariables(c string, vars map[string]string) string {
+	if len(vars) == 0 {
+		return c
+	}
+
+	return variableRef.ReplaceAllStringFunc(c, func(ref string) string {
+		match := variableRef.FindStringSubmatch(ref)
+		name := match[1]
+		if name == "" {
+			name = match[2]
+		}
+		if value, ok := vars[name]; ok && name != "" {
+			return value
+		}
+		return ref
+	})
+}
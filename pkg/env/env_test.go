@@ -52,3 +52,60 @@ func TestExpand(t *testing.T) {
 		})
 	}
 }
+
+func TestExpandVariables(t *testing.T) {
+	os.Setenv("KUTTL_TEST_123", "hello")
+	t.Cleanup(func() {
+		os.Unsetenv("KUTTL_TEST_123")
+	})
+
+	vars := map[string]string{
+		"POD_NAME": "pod-x7k2p",
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: `expand variables`,
+			in:   "$POD_NAME ${POD_NAME}-log",
+			want: "pod-x7k2p pod-x7k2p-log",
+		},
+		{
+			name: `do not expand os and unknown variables`,
+			in:   "$KUTTL_TEST_123 ${NOT_PROVIDED} $POD_NAMES",
+			want: "$KUTTL_TEST_123 ${NOT_PROVIDED} $POD_NAMES",
+		},
+		{
+			name: `do not touch $$`,
+			in:   "test $$ $$POD_NAME",
+			want: "test $$ $$POD_NAME",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ExpandVariables(tt.in, vars))
+		})
+	}
+}
+
+func TestExpandWithMapStrict(t *testing.T) {
+	os.Setenv("KUTTL_TEST_123", "hello")
+	t.Cleanup(func() {
+		os.Unsetenv("KUTTL_TEST_123")
+	})
+
+	expanded, err := ExpandWithMapStrict("$KUTTL_TEST_123 $$ ${EXPAND_ME}", map[string]string{
+		"EXPAND_ME": "world",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello $ world", expanded)
+
+	_, err = ExpandWithMapStrict("$KUTTL_TEST_123 $DOES_NOT_EXIST_1234 ${NOT_PROVIDED}", nil)
+	assert.EqualError(t, err, "undefined variables: DOES_NOT_EXIST_1234, NOT_PROVIDED")
+}
@@ -46,6 +46,16 @@ type Failure struct {
 	Type    string `xml:"type,attr" json:"type,omitempty"`
 }
 
+// Warning defines a warning returned by the API server while applying an object.
+type Warning struct {
+	// Step is the name of the test step applying the object.
+	Step string `xml:"step,attr" json:"step"`
+	// Object identifies the object the warning was returned for.
+	Object string `xml:"object,attr" json:"object"`
+	// Text is the text of the warning.
+	Text string `xml:",chardata" json:"text"`
+}
+
 // Testcase is the finest grain level of reporting, it is the kuttl test (which contains steps).
 type Testcase struct {
 	// Classname is a junit thing, for kuttl it is the testsuite name.
@@ -62,6 +72,9 @@ type Testcase struct {
 	Assertions int `xml:"assertions,attr" json:"assertions,omitempty"`
 	// Failure defines a failure in this Testcase.
 	Failure *Failure `xml:"failure" json:"failure,omitempty"`
+	// Warnings are the warnings returned by the API server while applying the objects of the test.
+	// This element is not in the mentioned XML schema but should be gracefully ignored by readers who do not expect it.
+	Warnings []Warning `xml:"warning" json:"warnings,omitempty"`
 
 	// end is not reported.  It is used to calculate duration times for testcase and testsuite.
 	end time.Time
@@ -30,6 +30,9 @@ func TestXML(t *testing.T) {
 AssertionError`,
 			Message: "test failure",
 		},
+		Warnings: []Warning{
+			{Step: "1-install", Object: "Deployment:kuttl-test/hello", Text: "would violate PodSecurity \"restricted:latest\": runAsNonRoot != true"},
+		},
 	}
 	suite := &Testsuite{
 		Tests:    9,
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+// CheckAbsent checks that the objects referenced by refs are not found. The objects referenced by labels instead of
+// a name must all be gone. It returns an error describing the deletion state of each remaining object.
+func (s *Step) CheckAbsent(namespace string, refs []harness.ObjectReference) []error {
+	if len(refs) == 0 {
+		return nil
+	}
+
+	cl, err := s.Client(false)
+	if err != nil {
+		return []error{err}
+	}
+
+	dClient, err := s.DiscoveryClient()
+	if err != nil {
+		return []error{err}
+	}
+
+	errs := []error{}
+
+	for _, ref := range refs {
+		remaining, err := getReferenced(cl, dClient, ref, namespace, nil)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for i := range remaining {
+			errs = append(errs, fmt.Errorf("%s still exists: %s", testutils.ResourceID(&remaining[i]), deletionState(&remaining[i])))
+		}
+	}
+
+	return errs
+}
+
+// deletionState describes why obj, which should be gone, still exists: whether it is being deleted, its pending
+// finalizers and its owners.
+func deletionState(obj *unstructured.Unstructured) string {
+	var state string
+	switch deletionTimestamp := obj.GetDeletionTimestamp(); {
+	case deletionTimestamp == nil:
+		state = "not being deleted"
+	case len(obj.GetFinalizers()) > 0:
+		state = fmt.Sprintf("stuck on finalizers %s since %s", strings.Join(obj.GetFinalizers(), ", "), deletionTimestamp.UTC().Format(time.RFC3339))
+	default:
+		state = fmt.Sprintf("being deleted since %s", deletionTimestamp.UTC().Format(time.RFC3339))
+	}
+
+	owners := []string{}
+	for _, owner := range obj.GetOwnerReferences() {
+		owners = append(owners, fmt.Sprintf("%s %s", owner.Kind, owner.Name))
+	}
+	if len(owners) > 0 {
+		state += fmt.Sprintf(", owned by %s", strings.Join(owners, ", "))
+	}
+	return state
+}
+
+func validateAbsent(refs []harness.ObjectReference) error {
+	for i, ref := range refs {
+		if ref.APIVersion == "" || ref.Kind == "" {
+			return fmt.Errorf("absent object %d must have an apiVersion and a kind", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+func TestCheckAbsent(t *testing.T) {
+	deletionTimestamp := metav1.NewTime(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+
+	remaining := testutils.NewV1Pod("remaining", testNamespace, "default")
+	remaining.Labels = map[string]string{"app": "web"}
+	terminating := testutils.NewV1Pod("terminating", testNamespace, "default")
+	terminating.Labels = map[string]string{"app": "db"}
+	terminating.DeletionTimestamp = &deletionTimestamp
+	terminating.Finalizers = []string{"example.com/protect", "example.com/backup"}
+	terminating.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "db"}}
+
+	cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(remaining, terminating).Build()
+
+	step := Step{
+		Logger:          testutils.NewTestLogger(t, ""),
+		Client:          func(bool) (client.Client, error) { return cl, nil },
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+	}
+
+	for _, test := range []struct {
+		name         string
+		refs         []harness.ObjectReference
+		expectedErrs []string
+	}{
+		{
+			name: "objects are gone",
+			refs: []harness.ObjectReference{podReference("deleted", nil), podReference("", map[string]string{"app": "cache"})},
+		},
+		{
+			name:         "object not being deleted",
+			refs:         []harness.ObjectReference{podReference("remaining", nil)},
+			expectedErrs: []string{"Pod:world/remaining still exists: not being deleted"},
+		},
+		{
+			name: "object stuck on finalizers",
+			refs: []harness.ObjectReference{podReference("", map[string]string{"app": "db"})},
+			expectedErrs: []string{
+				"Pod:world/terminating still exists: stuck on finalizers example.com/protect, example.com/backup since 2024-05-01T12:00:00Z, owned by StatefulSet db",
+			},
+		},
+		{
+			name: "objects selected by labels",
+			refs: []harness.ObjectReference{podReference("", nil)},
+			expectedErrs: []string{
+				"Pod:world/remaining still exists: not being deleted",
+				"Pod:world/terminating still exists: stuck on finalizers example.com/protect, example.com/backup since 2024-05-01T12:00:00Z, owned by StatefulSet db",
+			},
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			errs := []string{}
+			for _, err := range step.CheckAbsent(testNamespace, test.refs) {
+				errs = append(errs, err.Error())
+			}
+			if len(test.expectedErrs) == 0 {
+				assert.Empty(t, errs)
+			} else {
+				assert.ElementsMatch(t, test.expectedErrs, errs)
+			}
+		})
+	}
+}
+
+func TestValidateAbsent(t *testing.T) {
+	assert.NoError(t, validateAbsent([]harness.ObjectReference{podReference("hello", nil)}))
+
+	ref := podReference("hello", nil)
+	ref.APIVersion = ""
+	assert.EqualError(t, validateAbsent([]harness.ObjectReference{podReference("", nil), ref}), "absent object 1 must have an apiVersion and a kind")
+}
@@ -0,0 +1,163 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+)
+
+// CheckAccess checks whether the subjects of checks are allowed to perform their actions, like kubectl auth can-i.
+// The access of the subject of the step is reviewed with the client of the step, the access of other subjects with
+// the client of the harness, as the subject of the step may not be allowed to review it.
+func (s *Step) CheckAccess(namespace string, checks []harness.AccessCheck) []error {
+	cl, err := s.Client(false)
+	if err != nil {
+		return []error{err}
+	}
+
+	harnessClient := s.HarnessClient
+	if harnessClient == nil {
+		harnessClient = s.Client
+	}
+	harnessCl, err := harnessClient(false)
+	if err != nil {
+		return []error{err}
+	}
+
+	errs := []error{}
+
+	for _, check := range checks {
+		reviewer := cl
+		if check.Subject != nil {
+			reviewer = harnessCl
+		}
+		status, err := reviewAccess(reviewer, check, namespace)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", describeAccessCheck(check, namespace), err))
+			continue
+		}
+		if status.Allowed == check.Allowed {
+			continue
+		}
+
+		reason := status.Reason
+		if status.EvaluationError != "" {
+			reason = strings.TrimSpace(reason + " " + status.EvaluationError)
+		}
+		if reason != "" {
+			reason = fmt.Sprintf(" (%s)", reason)
+		}
+		errs = append(errs, fmt.Errorf("%s: expected %s, got %s%s", describeAccessCheck(check, namespace), yesNo(check.Allowed), yesNo(status.Allowed), reason))
+	}
+
+	return errs
+}
+
+// reviewAccess reviews the access of the subject of check with cl, or of the client if the check has no subject.
+func reviewAccess(cl client.Client, check harness.AccessCheck, namespace string) (authorizationv1.SubjectAccessReviewStatus, error) {
+	attributes := &authorizationv1.ResourceAttributes{
+		Namespace:   accessCheckNamespace(check, namespace),
+		Verb:        check.Verb,
+		Group:       check.Group,
+		Resource:    check.Resource,
+		Subresource: check.Subresource,
+		Name:        check.Name,
+	}
+
+	if check.Subject == nil {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: attributes},
+		}
+		err := cl.Create(context.TODO(), review)
+		return review.Status, err
+	}
+
+	user, groups := check.Subject.User, []string{}
+	if check.Subject.ServiceAccount != "" {
+		user, groups = serviceAccountSubject(check.Subject.ServiceAccount, namespace)
+	}
+	groups = append(groups, check.Subject.Groups...)
+
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: attributes,
+			User:               user,
+			// the API server adds this group to all authenticated subjects, reviews must add it explicitly
+			Groups: append(groups, "system:authenticated"),
+		},
+	}
+	err := cl.Create(context.TODO(), review)
+	return review.Status, err
+}
+
+func accessCheckNamespace(check harness.AccessCheck, namespace string) string {
+	switch {
+	case check.AllNamespaces:
+		return ""
+	case check.Namespace != "":
+		return check.Namespace
+	default:
+		return namespace
+	}
+}
+
+// describeAccessCheck describes check like the arguments of kubectl auth can-i.
+func describeAccessCheck(check harness.AccessCheck, namespace string) string {
+	resource := check.Resource
+	if check.Group != "" {
+		resource += "." + check.Group
+	}
+	if check.Name != "" {
+		resource += "/" + check.Name
+	}
+
+	description := fmt.Sprintf("can-i %s %s", check.Verb, resource)
+	if check.Subresource != "" {
+		description += " --subresource " + check.Subresource
+	}
+	if check.AllNamespaces {
+		description += " --all-namespaces"
+	} else {
+		description += " -n " + accessCheckNamespace(check, namespace)
+	}
+
+	switch {
+	case check.Subject == nil:
+	case check.Subject.ServiceAccount != "":
+		user, _ := serviceAccountSubject(check.Subject.ServiceAccount, namespace)
+		description += " --as " + user
+	default:
+		description += " --as " + check.Subject.User
+	}
+	return description
+}
+
+func yesNo(allowed bool) string {
+	if allowed {
+		return "yes"
+	}
+	return "no"
+}
+
+func validateAccessChecks(checks []harness.AccessCheck) error {
+	for i, check := range checks {
+		if check.Verb == "" || check.Resource == "" {
+			return fmt.Errorf("access check %d must have a verb and a resource", i)
+		}
+		if check.AllNamespaces && check.Namespace != "" {
+			return errors.New("namespace and allNamespaces of access checks are exclusive")
+		}
+		if check.Subject != nil {
+			if err := validateImpersonation(check.Subject); err != nil {
+				return fmt.Errorf("invalid subject of access check %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+func TestCheckAccess(t *testing.T) {
+	for _, test := range []struct {
+		name           string
+		check          harness.AccessCheck
+		expectedReview authorizationv1.SubjectAccessReviewSpec
+		expectedErr    string
+	}{
+		{
+			name:  "self subject allowed",
+			check: harness.AccessCheck{Verb: "create", Group: "apps", Resource: "deployments", Allowed: true},
+			expectedReview: authorizationv1.SubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{Namespace: testNamespace, Verb: "create", Group: "apps", Resource: "deployments"},
+			},
+		},
+		{
+			name: "service account denied in another namespace",
+			check: harness.AccessCheck{
+				Subject:   &harness.Impersonation{ServiceAccount: "deployer"},
+				Verb:      "delete",
+				Resource:  "pods",
+				Namespace: "tenant-b",
+			},
+			expectedReview: authorizationv1.SubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{Namespace: "tenant-b", Verb: "delete", Resource: "pods"},
+				User:               "system:serviceaccount:world:deployer",
+				Groups:             []string{"system:serviceaccounts", "system:serviceaccounts:world", "system:authenticated"},
+			},
+		},
+		{
+			name: "user unexpectedly denied",
+			check: harness.AccessCheck{
+				Subject:       &harness.Impersonation{User: "tenant-a", Groups: []string{"tenants"}},
+				Verb:          "list",
+				Resource:      "namespaces",
+				AllNamespaces: true,
+				Allowed:       true,
+			},
+			expectedReview: authorizationv1.SubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{Verb: "list", Resource: "namespaces"},
+				User:               "tenant-a",
+				Groups:             []string{"tenants", "system:authenticated"},
+			},
+			expectedErr: "can-i list namespaces --all-namespaces --as tenant-a: expected yes, got no (no RBAC policy matched)",
+		},
+		{
+			name:  "self subject unexpectedly allowed",
+			check: harness.AccessCheck{Verb: "get", Resource: "pods", Subresource: "log", Name: "hello"},
+			expectedReview: authorizationv1.SubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{Namespace: testNamespace, Verb: "get", Resource: "pods", Subresource: "log", Name: "hello"},
+			},
+			expectedErr: `can-i get pods/hello --subresource log -n world: expected no, got yes (RBAC: allowed by RoleBinding "view/world" of ClusterRole "view" to User "admin")`,
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			cl := &reviewClient{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()}
+
+			step := Step{
+				Logger: testutils.NewTestLogger(t, ""),
+				Client: func(bool) (client.Client, error) { return cl, nil },
+			}
+
+			errs := step.CheckAccess(testNamespace, []harness.AccessCheck{test.check})
+			if test.expectedErr != "" {
+				assert.Len(t, errs, 1)
+				assert.EqualError(t, errs[0], test.expectedErr)
+			} else {
+				assert.Equal(t, []error{}, errs)
+			}
+			assert.Equal(t, test.expectedReview, cl.review)
+		})
+	}
+}
+
+func TestCheckAccessImpersonated(t *testing.T) {
+	harnessCl := &reviewClient{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()}
+	impersonatedCl := &reviewClient{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), selfOnly: true}
+
+	step := Step{
+		Logger:        testutils.NewTestLogger(t, ""),
+		Client:        func(bool) (client.Client, error) { return impersonatedCl, nil },
+		HarnessClient: func(bool) (client.Client, error) { return harnessCl, nil },
+	}
+
+	// the access of the impersonated subject is reviewed by itself, the access of other subjects by the harness
+	errs := step.CheckAccess(testNamespace, []harness.AccessCheck{
+		{Verb: "get", Resource: "pods", Allowed: true},
+		{Subject: &harness.Impersonation{User: "tenant-a"}, Verb: "get", Resource: "pods"},
+	})
+	assert.Equal(t, []error{}, errs)
+	assert.Equal(t, authorizationv1.SubjectAccessReviewSpec{
+		ResourceAttributes: &authorizationv1.ResourceAttributes{Namespace: testNamespace, Verb: "get", Resource: "pods"},
+	}, impersonatedCl.review)
+	assert.Equal(t, "tenant-a", harnessCl.review.User)
+}
+
+// reviewClient emulates the authorization of the API server: the client is allowed to do anything, other subjects
+// are allowed nothing.
+type reviewClient struct {
+	client.Client
+	review authorizationv1.SubjectAccessReviewSpec
+	// selfOnly forbids the review of the access of other subjects, like for subjects without cluster-wide access.
+	selfOnly bool
+}
+
+func (c *reviewClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	switch review := obj.(type) {
+	case *authorizationv1.SelfSubjectAccessReview:
+		c.review = authorizationv1.SubjectAccessReviewSpec{ResourceAttributes: review.Spec.ResourceAttributes}
+		review.Status = authorizationv1.SubjectAccessReviewStatus{
+			Allowed: true,
+			Reason:  `RBAC: allowed by RoleBinding "view/world" of ClusterRole "view" to User "admin"`,
+		}
+		return nil
+	case *authorizationv1.SubjectAccessReview:
+		if c.selfOnly {
+			return k8serrors.NewForbidden(authorizationv1.Resource("subjectaccessreviews"), "", errors.New("impersonated"))
+		}
+		c.review = review.Spec
+		review.Status = authorizationv1.SubjectAccessReviewStatus{Reason: "no RBAC policy matched"}
+		return nil
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func TestValidateAccessChecks(t *testing.T) {
+	assert.NoError(t, validateAccessChecks([]harness.AccessCheck{{Verb: "get", Resource: "pods", Subject: &harness.Impersonation{User: "tenant-a"}}}))
+	assert.EqualError(t, validateAccessChecks([]harness.AccessCheck{{Verb: "get"}}), "access check 0 must have a verb and a resource")
+	assert.EqualError(t, validateAccessChecks([]harness.AccessCheck{{Verb: "get", Resource: "pods", Namespace: "world", AllNamespaces: true}}), "namespace and allNamespaces of access checks are exclusive")
+	assert.EqualError(t, validateAccessChecks([]harness.AccessCheck{{Verb: "get", Resource: "pods", Subject: &harness.Impersonation{}}}), "invalid subject of access check 0: exactly one of user and serviceAccount must be set")
+}
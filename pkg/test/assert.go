@@ -36,7 +36,7 @@ func Assert(namespace string, timeout int, assertFiles ...string) error {
 		// start fresh
 		testErrors = []error{}
 		for _, expected := range objects {
-			testErrors = append(testErrors, s.CheckResource(expected, namespace, nil)...)
+			testErrors = append(testErrors, s.CheckResource(expected, namespace, nil, nil)...)
 		}
 
 		if len(testErrors) == 0 {
@@ -81,7 +81,7 @@ func Errors(namespace string, timeout int, errorFiles ...string) error {
 		// start fresh
 		testErrors = []error{}
 		for _, expected := range objects {
-			if err := s.CheckResourceAbsent(expected, namespace); err != nil {
+			if err := s.CheckResourceAbsent(expected, namespace, nil); err != nil {
 				testErrors = append(testErrors, err)
 			}
 		}
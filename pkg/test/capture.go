@@ -0,0 +1,133 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+// variableNameRegex matches valid variable names, which can be referenced as $NAME or ${NAME}.
+var variableNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedVariables are the variables which are always set for commands and can not be captured.
+var reservedVariables = map[string]bool{
+	"NAMESPACE":  true,
+	"KUBECONFIG": true,
+	"PATH":       true,
+}
+
+// Capture captures the values of the live objects referenced by captures into s.Variables.
+func (s *Step) Capture(namespace string, captures []harness.Capture) error {
+	if s.Variables == nil {
+		s.Variables = map[string]string{}
+	}
+
+	for _, capture := range captures {
+		obj, err := s.captureObject(namespace, capture.Object)
+		if err != nil {
+			return fmt.Errorf("capturing %s: %w", capture.Variable, err)
+		}
+
+		value, err := captureValue(obj, capture.Path)
+		if err != nil {
+			return fmt.Errorf("capturing %s from %s: %w", capture.Variable, testutils.ResourceID(obj), err)
+		}
+
+		s.Logger.Logf("captured %s from %s", capture.Variable, testutils.ResourceID(obj))
+		s.Variables[capture.Variable] = value
+	}
+
+	return nil
+}
+
+// captureObject returns the object referenced by ref, if ref has no name its labels must match exactly one object.
+func (s *Step) captureObject(namespace string, ref harness.ObjectReference) (*unstructured.Unstructured, error) {
+	cl, err := s.Client(false)
+	if err != nil {
+		return nil, err
+	}
+
+	dClient, err := s.DiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+
+	gvk := ref.GroupVersionKind()
+
+	obj := testutils.NewResource(gvk.GroupVersion().String(), gvk.Kind, ref.Name, "")
+
+	objNs := namespace
+	if ref.Namespace != "" {
+		objNs = ref.Namespace
+	}
+
+	_, objNs, err = testutils.Namespaced(dClient, obj, objNs)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref.Name != "" {
+		if err := testutils.WithMappingRefresh(cl, func() error {
+			return cl.Get(context.TODO(), client.ObjectKey{Namespace: objNs, Name: ref.Name}, obj)
+		}); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	}
+
+	actuals, err := list(cl, gvk, objNs, selector{labels: labels.SelectorFromSet(ref.Labels)})
+	if err != nil {
+		return nil, err
+	}
+	if len(actuals) != 1 {
+		return nil, fmt.Errorf("expected exactly one %s matching labels %v, found %d", gvk.Kind, ref.Labels, len(actuals))
+	}
+	return &actuals[0], nil
+}
+
+// captureValue evaluates path against obj. Strings are returned as is, all other values as JSON.
+func captureValue(obj *unstructured.Unstructured, path string) (string, error) {
+	result, err := testutils.EvaluateExpression("("+path+")", obj.UnstructuredContent())
+	if err != nil {
+		return "", err
+	}
+
+	switch value := result.(type) {
+	case nil:
+		return "", fmt.Errorf("path %s did not match any value", path)
+	case string:
+		return value, nil
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+}
+
+func validateCaptures(captures []harness.Capture) error {
+	for _, capture := range captures {
+		if !variableNameRegex.MatchString(capture.Variable) {
+			return fmt.Errorf("invalid variable name %q", capture.Variable)
+		}
+		if reservedVariables[capture.Variable] {
+			return fmt.Errorf("variable %s is reserved", capture.Variable)
+		}
+		if capture.Path == "" {
+			return fmt.Errorf("path of variable %s must be set", capture.Variable)
+		}
+		if capture.Object.Kind == "" || capture.Object.APIVersion == "" {
+			return fmt.Errorf("object of variable %s must have an apiVersion and kind", capture.Variable)
+		}
+	}
+	return nil
+}
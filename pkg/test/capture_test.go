@@ -0,0 +1,127 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+func TestCapture(t *testing.T) {
+	podRef := func(name string, labels map[string]string) harness.ObjectReference {
+		return harness.ObjectReference{
+			ObjectReference: corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Name: name},
+			Labels:          labels,
+		}
+	}
+
+	for _, test := range []struct {
+		name          string
+		capture       harness.Capture
+		expectedValue string
+		expectedErr   string
+	}{
+		{
+			name:          "capture by name",
+			capture:       harness.Capture{Variable: "SA", Object: podRef("hello-x7k2p", nil), Path: "spec.serviceAccountName"},
+			expectedValue: "default",
+		},
+		{
+			name:          "capture by labels",
+			capture:       harness.Capture{Variable: "POD_NAME", Object: podRef("", map[string]string{"app": "hello"}), Path: "metadata.name"},
+			expectedValue: "hello-x7k2p",
+		},
+		{
+			name:          "capture non string values as json",
+			capture:       harness.Capture{Variable: "LABELS", Object: podRef("hello-x7k2p", nil), Path: "metadata.labels"},
+			expectedValue: `{"app":"hello"}`,
+		},
+		{
+			name:        "labels match multiple objects",
+			capture:     harness.Capture{Variable: "POD_NAME", Object: podRef("", nil), Path: "metadata.name"},
+			expectedErr: "capturing POD_NAME: expected exactly one Pod matching labels map[], found 2",
+		},
+		{
+			name:        "path does not match",
+			capture:     harness.Capture{Variable: "IP", Object: podRef("hello-x7k2p", nil), Path: "status.podIP"},
+			expectedErr: "capturing IP from Pod:world/hello-x7k2p: path status.podIP did not match any value",
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			pod := testutils.NewV1Pod("hello-x7k2p", testNamespace, "default")
+			pod.Labels = map[string]string{"app": "hello"}
+
+			cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(
+				pod, testutils.NewV1Pod("other", testNamespace, "default"),
+			).Build()
+
+			step := Step{
+				Client:          func(bool) (client.Client, error) { return cl, nil },
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+				Logger:          testutils.NewTestLogger(t, ""),
+			}
+
+			err := step.Capture(testNamespace, []harness.Capture{test.capture})
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedValue, step.Variables[test.capture.Variable])
+		})
+	}
+}
+
+func TestRunCapturedVariables(t *testing.T) {
+	cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	variables := map[string]string{}
+
+	newStep := func(index int) *Step {
+		return &Step{
+			Index:           index,
+			Assert:          &harness.TestAssert{Timeout: 1},
+			Variables:       variables,
+			Client:          func(bool) (client.Client, error) { return cl, nil },
+			DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+			Logger:          testutils.NewTestLogger(t, ""),
+		}
+	}
+
+	first := newStep(1)
+	first.Apply = []apply{{object: testutils.WithLabels(t, testutils.NewPod("hello-x7k2p", ""), map[string]string{"app": "hello"})}}
+	first.Assert.Capture = []harness.Capture{{
+		Variable: "POD_NAME",
+		Object: harness.ObjectReference{
+			ObjectReference: corev1.ObjectReference{APIVersion: "v1", Kind: "Pod"},
+			Labels:          map[string]string{"app": "hello"},
+		},
+		Path: "metadata.name",
+	}}
+
+	second := newStep(2)
+	second.Apply = []apply{{object: testutils.NewPod("${POD_NAME}-copy", "")}}
+	second.Asserts = []asserts{{object: testutils.NewPod("hello-x7k2p-copy", "")}}
+
+	assert.Equal(t, []error{}, first.Run(t, testNamespace))
+	assert.Equal(t, map[string]string{"POD_NAME": "hello-x7k2p"}, variables)
+	assert.Equal(t, []error{}, second.Run(t, testNamespace))
+}
+
+func TestValidateCaptures(t *testing.T) {
+	object := harness.ObjectReference{ObjectReference: corev1.ObjectReference{APIVersion: "v1", Kind: "Pod"}}
+
+	assert.NoError(t, validateCaptures([]harness.Capture{{Variable: "POD_NAME", Object: object, Path: "metadata.name"}}))
+	assert.EqualError(t, validateCaptures([]harness.Capture{{Variable: "POD-NAME", Object: object, Path: "metadata.name"}}), `invalid variable name "POD-NAME"`)
+	assert.EqualError(t, validateCaptures([]harness.Capture{{Variable: "NAMESPACE", Object: object, Path: "metadata.name"}}), "variable NAMESPACE is reserved")
+	assert.EqualError(t, validateCaptures([]harness.Capture{{Variable: "POD_NAME", Object: object}}), "path of variable POD_NAME must be set")
+	assert.EqualError(t, validateCaptures([]harness.Capture{{Variable: "POD_NAME", Path: "metadata.name"}}), "object of variable POD_NAME must have an apiVersion and kind")
+}
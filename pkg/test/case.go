@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"sort"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -17,13 +18,16 @@ import (
 	eventsv1 "k8s.io/api/events/v1"
 	eventsbeta1 "k8s.io/api/events/v1beta1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/discovery"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
 	"github.com/kyverno/kuttl/pkg/report"
 	testutils "github.com/kyverno/kuttl/pkg/test/utils"
 )
@@ -43,10 +47,34 @@ type Case struct {
 
 	Client          func(forceNew bool) (client.Client, error)
 	DiscoveryClient func() (discovery.DiscoveryInterface, error)
+	// Config returns the client configuration of the test case, steps impersonating a subject are based on it.
+	Config func() (*rest.Config, error)
+
+	// Variables of the test suite, available to all steps.
+	Variables map[string]string
+	// Templating is the default templating configuration of the steps.
+	Templating *harness.Templating
+	// ServerSideApply is the default server-side apply configuration of the steps.
+	ServerSideApply *harness.ServerSideApply
+	// Retry is the default retry policy of the steps.
+	Retry *retryPolicy
 
 	Logger testutils.Logger
 	// Suppress is used to suppress logs
 	Suppress []string
+
+	// clientLock guards kubeconfigClients.
+	clientLock sync.Mutex
+	// kubeconfigClients are the clients of the kubeconfigs of the steps, by kubeconfig.
+	kubeconfigClients map[string]*kubeconfigClient
+}
+
+// kubeconfigClient is the client of a kubeconfig along with its cached discovery client, which is shared with the
+// REST mapper of the client.
+type kubeconfigClient struct {
+	client  client.Client
+	dclient discovery.CachedDiscoveryInterface
+	mapper  meta.ResettableRESTMapper
 }
 
 type namespace struct {
@@ -331,7 +359,7 @@ func (t *Case) Run(test *testing.T, tc *report.Testcase) {
 			continue
 		}
 
-		cl, err := newClient(testStep.Kubeconfig)(false)
+		cl, err := t.kubeconfigClient(testStep.Kubeconfig)(false)
 		if err != nil {
 			tc.Failure = report.NewFailure(err.Error(), nil)
 			test.Fatal(err)
@@ -347,20 +375,48 @@ func (t *Case) Run(test *testing.T, tc *report.Testcase) {
 		}
 	}
 
+	// variables captured by a step are available to all following steps
+	variables := map[string]string{}
+	for key, value := range t.Variables {
+		variables[key] = value
+	}
+
+	// the finally commands of the started steps run once the steps are done, even if the test case fails
+	started := []*Step{}
+	defer func() {
+		t.runFinally(test, tc, ns.Name, started)
+	}()
+
 	for _, testStep := range t.Steps {
+		testStep.Variables = variables
+		testStep.HarnessClient = t.Client
+		if testStep.Kubeconfig != "" {
+			testStep.HarnessClient = t.kubeconfigClient(testStep.Kubeconfig)
+		}
+		if testStep.Impersonate != nil {
+			kubeconfig, err := t.impersonatingKubeconfig(test, testStep, ns.Name)
+			if err != nil {
+				tc.Failure = report.NewFailure(err.Error(), nil)
+				test.Fatal(err)
+			}
+			testStep.Kubeconfig = kubeconfig
+		}
 		testStep.Client = t.Client
 		if testStep.Kubeconfig != "" {
-			testStep.Client = newClient(testStep.Kubeconfig)
+			testStep.Client = t.kubeconfigClient(testStep.Kubeconfig)
 		}
 		testStep.DiscoveryClient = t.DiscoveryClient
 		if testStep.Kubeconfig != "" {
-			testStep.DiscoveryClient = newDiscoveryClient(testStep.Kubeconfig)
+			testStep.DiscoveryClient = t.kubeconfigDiscoveryClient(testStep.Kubeconfig)
 		}
 		testStep.Logger = t.Logger.WithPrefix(testStep.String())
 		tc.Assertions += len(testStep.Asserts)
 		tc.Assertions += len(testStep.Errors)
 
-		if errs := testStep.Run(test, ns.Name); len(errs) > 0 {
+		started = append(started, testStep)
+		errs := testStep.Run(test, ns.Name)
+		tc.Warnings = append(tc.Warnings, testStep.Warnings...)
+		if len(errs) > 0 {
 			caseErr := fmt.Errorf("failed in step %s", testStep.String())
 			tc.Failure = report.NewFailure(caseErr.Error(), errs)
 
@@ -379,6 +435,23 @@ func (t *Case) Run(test *testing.T, tc *report.Testcase) {
 	}
 }
 
+// runFinally runs the finally commands of steps in reverse order. A failure is reported, but does not prevent the
+// finally commands of the other steps from running.
+func (t *Case) runFinally(test *testing.T, tc *report.Testcase, namespace string, steps []*Step) {
+	for i := len(steps) - 1; i >= 0; i-- {
+		testStep := steps[i]
+		if err := testStep.RunFinally(namespace); err != nil {
+			caseErr := fmt.Errorf("finally commands failed in step %s", testStep.String())
+			if tc.Failure == nil {
+				tc.Failure = report.NewFailure(caseErr.Error(), []error{err})
+			}
+
+			test.Error(caseErr)
+			test.Error(err)
+		}
+	}
+}
+
 func (t *Case) determineNamespace() *namespace {
 	ns := &namespace{
 		Name:        t.PreferredNamespace,
@@ -461,13 +534,16 @@ func (t *Case) LoadTestSteps() error {
 
 	for index, files := range testStepFiles {
 		testStep := &Step{
-			Timeout:    t.Timeout,
-			Index:      int(index),
-			SkipDelete: t.SkipDelete,
-			Dir:        t.Dir,
-			Asserts:    []asserts{},
-			Apply:      []apply{},
-			Errors:     []client.Object{},
+			Timeout:         t.Timeout,
+			Index:           int(index),
+			SkipDelete:      t.SkipDelete,
+			Dir:             t.Dir,
+			Asserts:         []asserts{},
+			Apply:           []apply{},
+			Errors:          []asserts{},
+			Templating:      t.Templating,
+			ServerSideApply: t.ServerSideApply,
+			Retry:           t.Retry,
 		}
 		for _, file := range files {
 			if err := testStep.LoadYAML(file); err != nil {
@@ -486,26 +562,70 @@ func (t *Case) LoadTestSteps() error {
 	return nil
 }
 
-func newClient(kubeconfig string) func(bool) (client.Client, error) {
-	return func(bool) (client.Client, error) {
+// kubeconfigClient returns the client of kubeconfig. The client is created once per test case, or again if forceNew
+// is true.
+func (t *Case) kubeconfigClient(kubeconfig string) func(bool) (client.Client, error) {
+	return func(forceNew bool) (client.Client, error) {
+		t.clientLock.Lock()
+		defer t.clientLock.Unlock()
+
+		c, err := t.clientsFor(kubeconfig)
+		if err != nil {
+			return nil, err
+		}
+		if c.client != nil && !forceNew {
+			return c.client, nil
+		}
+
 		config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
 		if err != nil {
 			return nil, err
 		}
 
-		return testutils.NewRetryClient(config, client.Options{
+		c.client, err = testutils.NewRetryClient(config, client.Options{
 			Scheme: testutils.Scheme(),
+			Mapper: c.mapper,
 		})
+		return c.client, err
 	}
 }
 
-func newDiscoveryClient(kubeconfig string) func() (discovery.DiscoveryInterface, error) {
+// kubeconfigDiscoveryClient returns the cached discovery client of kubeconfig, it is shared with the REST mapper of
+// the client of kubeconfig.
+func (t *Case) kubeconfigDiscoveryClient(kubeconfig string) func() (discovery.DiscoveryInterface, error) {
 	return func() (discovery.DiscoveryInterface, error) {
-		config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+		t.clientLock.Lock()
+		defer t.clientLock.Unlock()
+
+		c, err := t.clientsFor(kubeconfig)
 		if err != nil {
 			return nil, err
 		}
+		return c.dclient, nil
+	}
+}
+
+// clientsFor returns the clients of kubeconfig, the cached discovery client and the REST mapper are initialized on
+// first use. t.clientLock must be held.
+func (t *Case) clientsFor(kubeconfig string) (*kubeconfigClient, error) {
+	if c, ok := t.kubeconfigClients[kubeconfig]; ok {
+		return c, nil
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	dclient, err := testutils.NewCachedDiscoveryClient(config)
+	if err != nil {
+		return nil, err
+	}
 
-		return discovery.NewDiscoveryClientForConfig(config)
+	if t.kubeconfigClients == nil {
+		t.kubeconfigClients = map[string]*kubeconfigClient{}
 	}
+	c := &kubeconfigClient{dclient: dclient, mapper: testutils.NewRESTMapper(dclient)}
+	t.kubeconfigClients[kubeconfig] = c
+	return c, nil
 }
@@ -1,15 +1,19 @@
 package test
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"sigs.k8s.io/controller-runtime/pkg/client"
+	"k8s.io/client-go/rest"
 
 	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	"github.com/kyverno/kuttl/pkg/report"
 	testutils "github.com/kyverno/kuttl/pkg/test/utils"
 )
 
@@ -56,7 +60,7 @@ func TestLoadTestSteps(t *testing.T) {
 							}),
 						},
 					},
-					Errors: []client.Object{},
+					Errors: []asserts{},
 				},
 				{
 					Name:  "test-assert",
@@ -67,12 +71,14 @@ func TestLoadTestSteps(t *testing.T) {
 							APIVersion: "kuttl.dev/v1beta1",
 						},
 						Index: 1,
-						Delete: []harness.ObjectReference{
+						Delete: []harness.Deletion{
 							{
-								ObjectReference: corev1.ObjectReference{
-									APIVersion: "v1",
-									Kind:       "Pod",
-									Name:       "test",
+								ObjectReference: harness.ObjectReference{
+									ObjectReference: corev1.ObjectReference{
+										APIVersion: "v1",
+										Kind:       "Pod",
+										Name:       "test",
+									},
 								},
 							},
 						},
@@ -102,7 +108,7 @@ func TestLoadTestSteps(t *testing.T) {
 							"qosClass": "BestEffort",
 						})},
 					},
-					Errors: []client.Object{},
+					Errors: []asserts{},
 				},
 				{
 					Name:  "pod",
@@ -135,7 +141,7 @@ func TestLoadTestSteps(t *testing.T) {
 								"qosClass": "BestEffort",
 							})},
 					},
-					Errors: []client.Object{},
+					Errors: []asserts{},
 				},
 				{
 					Name:  "name-overridden",
@@ -179,7 +185,7 @@ func TestLoadTestSteps(t *testing.T) {
 							"restartPolicy": "Never",
 						})},
 					},
-					Errors: []client.Object{},
+					Errors: []asserts{},
 				},
 			},
 		},
@@ -234,7 +240,7 @@ func TestLoadTestSteps(t *testing.T) {
 							},
 						}},
 					},
-					Errors: []client.Object{},
+					Errors: []asserts{},
 				},
 			},
 		},
@@ -338,3 +344,55 @@ func TestGetIndexFromFile(t *testing.T) {
 		})
 	}
 }
+
+func TestRunFinally(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "finally")
+
+	newStep := func(index int, scripts ...string) *Step {
+		commands := []harness.Command{}
+		for _, script := range scripts {
+			commands = append(commands, harness.Command{Script: fmt.Sprintf("echo %s >> %s", script, output)})
+		}
+		return &Step{
+			Name:   fmt.Sprintf("step-%d", index),
+			Index:  index,
+			Logger: testutils.NewTestLogger(t, ""),
+			Step:   &harness.TestStep{Finally: commands},
+		}
+	}
+
+	test := &Case{Logger: testutils.NewTestLogger(t, "")}
+	tc := &report.Testcase{}
+	test.runFinally(t, tc, testNamespace, []*Step{newStep(0, "uninstall"), {Index: 1}, newStep(2, "dump", "cleanup")})
+
+	assert.Nil(t, tc.Failure)
+	actual, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "dump\ncleanup\nuninstall\n", string(actual))
+}
+
+func TestKubeconfigClient(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	f, err := os.Create(kubeconfig)
+	assert.NoError(t, err)
+	assert.NoError(t, testutils.Kubeconfig(&rest.Config{Host: "https://127.0.0.1:6443"}, f))
+	assert.NoError(t, f.Close())
+
+	test := &Case{}
+
+	// the client and the discovery client of a kubeconfig are created once and share the discovery information
+	cl, err := test.kubeconfigClient(kubeconfig)(false)
+	assert.NoError(t, err)
+	again, err := test.kubeconfigClient(kubeconfig)(false)
+	assert.NoError(t, err)
+	assert.Same(t, cl, again)
+
+	dClient, err := test.kubeconfigDiscoveryClient(kubeconfig)()
+	assert.NoError(t, err)
+	assert.Same(t, test.kubeconfigClients[kubeconfig].dclient, dClient)
+
+	fresh, err := test.kubeconfigClient(kubeconfig)(true)
+	assert.NoError(t, err)
+	assert.NotSame(t, cl, fresh)
+	assert.Same(t, cl.RESTMapper(), fresh.RESTMapper())
+}
@@ -0,0 +1,231 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+// DeleteExisting deletes any resources in the TestStep.Delete list prior to running the tests.
+// It then waits until the deleted objects, and the dependents of the deletions waiting for them, are gone.
+func (s *Step) DeleteExisting(namespace string) error {
+	cl, err := s.Client(false)
+	if err != nil {
+		return err
+	}
+
+	dClient, err := s.DiscoveryClient()
+	if err != nil {
+		return err
+	}
+
+	if s.Step == nil {
+		return nil
+	}
+
+	toWait := []unstructured.Unstructured{}
+
+	for _, deletion := range s.Step.Delete {
+		toDelete, err := getReferenced(cl, dClient, deletion.ObjectReference, namespace, &harness.Options{
+			LabelSelector: deletion.LabelSelector,
+			FieldSelector: deletion.FieldSelector,
+		})
+		if err != nil {
+			return fmt.Errorf("selecting the objects to delete: %w", err)
+		}
+
+		shouldWait := deletion.Wait == nil || *deletion.Wait
+		if shouldWait && deletion.WaitForDependents && len(toDelete) > 0 {
+			// the dependents are looked up before their owners are gone
+			dependents, err := s.findDependents(cl, dClient, toDelete, namespace)
+			if err != nil {
+				return fmt.Errorf("looking up the dependents of the objects to delete: %w", err)
+			}
+			toWait = append(toWait, dependents...)
+		}
+
+		for i := range toDelete {
+			obj := &toDelete[i]
+			err := s.retrying(testutils.ResourceID(obj), func(ctx context.Context) error {
+				return cl.Delete(ctx, obj, deleteOptions(deletion)...)
+			})
+			if err != nil && !k8serrors.IsNotFound(err) {
+				return err
+			}
+			if shouldWait {
+				toWait = append(toWait, *obj)
+			}
+		}
+	}
+
+	return s.waitForDeletion(cl, toWait)
+}
+
+// deleteOptions returns the options of the deletion requests of deletion.
+func deleteOptions(deletion harness.Deletion) []client.DeleteOption {
+	opts := []client.DeleteOption{}
+	if deletion.PropagationPolicy != nil {
+		opts = append(opts, client.PropagationPolicy(*deletion.PropagationPolicy))
+	}
+	if deletion.GracePeriodSeconds != nil {
+		opts = append(opts, client.GracePeriodSeconds(*deletion.GracePeriodSeconds))
+	}
+	return opts
+}
+
+// findDependents returns the objects owned by owners, directly or through other dependents. The dependents are
+// looked up in the namespaces of the owners and in namespace, cluster-scoped dependents only if an owner is
+// cluster-scoped. The kinds which can not be listed, e.g. because the step is not allowed to or the API is not
+// available, are skipped.
+func (s *Step) findDependents(cl client.Client, dClient discovery.DiscoveryInterface, owners []unstructured.Unstructured, namespace string) ([]unstructured.Unstructured, error) {
+	resources, err := discovery.ServerPreferredResources(dClient)
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+	resources = discovery.FilteredBy(discovery.SupportsAllVerbs{Verbs: []string{"list"}}, resources)
+
+	owned := map[types.UID]bool{}
+	namespaces := map[string]bool{namespace: true}
+	clusterScoped := false
+	for _, owner := range owners {
+		owned[owner.GetUID()] = true
+		if owner.GetNamespace() == "" {
+			clusterScoped = true
+		} else {
+			namespaces[owner.GetNamespace()] = true
+		}
+	}
+
+	candidates := []unstructured.Unstructured{}
+	for _, resourceList := range resources {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range resourceList.APIResources {
+			// subresources are not objects of their own
+			if strings.Contains(resource.Name, "/") {
+				continue
+			}
+
+			listNamespaces := []string{}
+			switch {
+			case !resource.Namespaced && !clusterScoped:
+				continue
+			case !resource.Namespaced:
+				listNamespaces = append(listNamespaces, "")
+			default:
+				for namespace := range namespaces {
+					listNamespaces = append(listNamespaces, namespace)
+				}
+			}
+
+			for _, namespace := range listNamespaces {
+				objs, err := list(cl, gv.WithKind(resource.Kind), namespace, selector{})
+				if isUnlistable(err) {
+					s.Logger.Logf("skipping %s while looking up dependents: %v", resource.Kind, err)
+					continue
+				} else if err != nil {
+					return nil, err
+				}
+				candidates = append(candidates, objs...)
+			}
+		}
+	}
+
+	dependents := []unstructured.Unstructured{}
+	for found := true; found; {
+		found = false
+		for _, candidate := range candidates {
+			if owned[candidate.GetUID()] {
+				continue
+			}
+			for _, ref := range candidate.GetOwnerReferences() {
+				if owned[ref.UID] {
+					owned[candidate.GetUID()] = true
+					dependents = append(dependents, candidate)
+					found = true
+					break
+				}
+			}
+		}
+	}
+	return dependents, nil
+}
+
+// isUnlistable returns true if err means that objects of a kind can not be listed, rather than a failure of the
+// request.
+func isUnlistable(err error) bool {
+	return k8serrors.IsForbidden(err) || k8serrors.IsNotFound(err) || k8serrors.IsMethodNotSupported(err) ||
+		k8serrors.IsServiceUnavailable(err)
+}
+
+// waitForDeletion waits until objs are gone, or until the timeout of the step expires. The returned error describes
+// the objects which are not gone, e.g. because of their finalizers.
+func (s *Step) waitForDeletion(cl client.Client, objs []unstructured.Unstructured) error {
+	var remaining []string
+	err := wait.PollImmediate(100*time.Millisecond, time.Duration(s.GetTimeout())*time.Second, func() (done bool, err error) {
+		remaining = []string{}
+		for _, obj := range objs {
+			actual := &unstructured.Unstructured{}
+			actual.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+			err = cl.Get(context.TODO(), testutils.ObjectKey(&obj), actual)
+			if err == nil {
+				remaining = append(remaining, fmt.Sprintf("%s is %s", testutils.ResourceID(actual), deletionState(actual)))
+			} else if !k8serrors.IsNotFound(err) {
+				return false, err
+			}
+		}
+
+		return len(remaining) == 0, nil
+	})
+	if errors.Is(err, wait.ErrWaitTimeout) && len(remaining) > 0 {
+		return fmt.Errorf("objects were not deleted: %s", strings.Join(remaining, "; "))
+	}
+	return err
+}
+
+func validateDeletions(deletions []harness.Deletion) error {
+	for _, deletion := range deletions {
+		ref := fmt.Sprintf("%s %s", deletion.Kind, deletion.Name)
+		if deletion.Name == "" {
+			ref = fmt.Sprintf("%s selected by labels %v", deletion.Kind, deletion.Labels)
+		}
+
+		if deletion.Name != "" && (deletion.LabelSelector != "" || deletion.FieldSelector != "") {
+			return fmt.Errorf("invalid delete of %s: selectors are only supported without a name", ref)
+		}
+		if _, err := newSelector(nil, &harness.Options{LabelSelector: deletion.LabelSelector, FieldSelector: deletion.FieldSelector}); err != nil {
+			return fmt.Errorf("invalid delete of %s: %w", ref, err)
+		}
+		if policy := deletion.PropagationPolicy; policy != nil {
+			switch *policy {
+			case metav1.DeletePropagationForeground, metav1.DeletePropagationBackground, metav1.DeletePropagationOrphan:
+			default:
+				return fmt.Errorf("invalid delete of %s: unknown propagation policy %q, expected one of %s, %s or %s", ref, *policy,
+					metav1.DeletePropagationForeground, metav1.DeletePropagationBackground, metav1.DeletePropagationOrphan)
+			}
+		}
+		if deletion.GracePeriodSeconds != nil && *deletion.GracePeriodSeconds < 0 {
+			return fmt.Errorf("invalid delete of %s: gracePeriodSeconds must not be negative", ref)
+		}
+		if deletion.WaitForDependents && deletion.Wait != nil && !*deletion.Wait {
+			return fmt.Errorf("invalid delete of %s: waitForDependents requires wait", ref)
+		}
+	}
+	return nil
+}
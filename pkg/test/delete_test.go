@@ -0,0 +1,263 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	coretesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+func TestStepDeleteExistingSelectors(t *testing.T) {
+	newPod := func(name string, labels map[string]string) *corev1.Pod {
+		pod := testutils.NewV1Pod(name, testNamespace, "default")
+		pod.Labels = labels
+		return pod
+	}
+	backend := newPod("backend", map[string]string{"app": "web", "tier": "backend"})
+	frontend := newPod("frontend", map[string]string{"app": "web", "tier": "frontend"})
+	db := newPod("db", map[string]string{"app": "db"})
+
+	cl := &deleteClient{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(backend, frontend, db).Build()}
+	foreground, grace := metav1.DeletePropagationForeground, int64(0)
+
+	step := Step{
+		Logger:  testutils.NewTestLogger(t, ""),
+		Timeout: 1,
+		Step: &harness.TestStep{
+			Delete: []harness.Deletion{
+				{
+					ObjectReference:    podReference("", map[string]string{"app": "web"}),
+					LabelSelector:      "tier!=frontend",
+					PropagationPolicy:  &foreground,
+					GracePeriodSeconds: &grace,
+				},
+			},
+		},
+		Client:          func(bool) (client.Client, error) { return cl, nil },
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+	}
+
+	assert.NoError(t, step.DeleteExisting(testNamespace))
+
+	assert.True(t, k8serrors.IsNotFound(cl.Get(context.TODO(), testutils.ObjectKey(backend), backend)))
+	assert.NoError(t, cl.Get(context.TODO(), testutils.ObjectKey(frontend), frontend))
+	assert.NoError(t, cl.Get(context.TODO(), testutils.ObjectKey(db), db))
+
+	assert.Len(t, cl.options, 1)
+	assert.Equal(t, &foreground, cl.options[0].PropagationPolicy)
+	assert.Equal(t, &grace, cl.options[0].GracePeriodSeconds)
+}
+
+func TestStepDeleteExistingWait(t *testing.T) {
+	protected := testutils.NewV1Pod("protected", testNamespace, "default")
+	protected.Finalizers = []string{"example.com/protect"}
+	protected.UID = "protected"
+	owned := testutils.NewV1Pod("owned", testNamespace, "default")
+	owned.OwnerReferences = []metav1.OwnerReference{{APIVersion: "v1", Kind: "Pod", Name: "owner", UID: "owner"}}
+	owner := testutils.NewV1Pod("owner", testNamespace, "default")
+	owner.UID = "owner"
+
+	disabled := false
+
+	for _, test := range []struct {
+		name        string
+		deletion    harness.Deletion
+		expectedErr string
+	}{
+		{
+			name:        "object stuck on finalizers",
+			deletion:    harness.Deletion{ObjectReference: podReference("protected", nil)},
+			expectedErr: `^objects were not deleted: Pod:world/protected is stuck on finalizers example\.com/protect since \d{4}-\d{2}-\d{2}T`,
+		},
+		{
+			name:     "object stuck on finalizers without waiting",
+			deletion: harness.Deletion{ObjectReference: podReference("protected", nil), Wait: &disabled},
+		},
+		{
+			name:     "dependents are not waited for",
+			deletion: harness.Deletion{ObjectReference: podReference("owner", nil)},
+		},
+		{
+			name:        "dependents not deleted",
+			deletion:    harness.Deletion{ObjectReference: podReference("owner", nil), WaitForDependents: true},
+			expectedErr: `^objects were not deleted: Pod:world/owned is not being deleted, owned by Pod owner$`,
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			// the fake client does not collect the garbage, the dependents of deleted objects are kept
+			cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(protected.DeepCopy(), owned.DeepCopy(), owner.DeepCopy()).Build()
+
+			step := Step{
+				Logger:          testutils.NewTestLogger(t, ""),
+				Timeout:         1,
+				Step:            &harness.TestStep{Delete: []harness.Deletion{test.deletion}},
+				Client:          func(bool) (client.Client, error) { return cl, nil },
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return listDiscoveryClient(), nil },
+			}
+
+			err := step.DeleteExisting(testNamespace)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Regexp(t, test.expectedErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestFindDependents(t *testing.T) {
+	owner := &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "owner", UID: "owner"}}
+	ownerRefs := []metav1.OwnerReference{{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "owner", UID: "owner"}}
+	owned := testutils.NewV1Pod("owned", testNamespace, "default")
+	owned.OwnerReferences = ownerRefs
+	elsewhere := testutils.NewV1Pod("elsewhere", "other", "default")
+	elsewhere.OwnerReferences = ownerRefs
+
+	cl := &listClient{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(owner, owned, elsewhere).Build()}
+
+	dClient := listDiscoveryClient().(*fakediscovery.FakeDiscovery)
+	dClient.Resources[0].APIResources = append(dClient.Resources[0].APIResources,
+		metav1.APIResource{Name: "secrets", Namespaced: true, Kind: "Secret", Verbs: metav1.Verbs{"list"}})
+	dClient.Resources = append(dClient.Resources, &metav1.APIResourceList{
+		GroupVersion: "rbac.authorization.k8s.io/v1",
+		APIResources: []metav1.APIResource{{Name: "clusterroles", Kind: "ClusterRole", Verbs: metav1.Verbs{"list"}}},
+	})
+
+	step := Step{Logger: testutils.NewTestLogger(t, "")}
+
+	ownerObj := testutils.NewResource("rbac.authorization.k8s.io/v1", "ClusterRole", "owner", "")
+	ownerObj.SetUID("owner")
+
+	// the secrets can not be listed, the dependents of the cluster-scoped owner are only looked up in the namespace
+	// of the step
+	dependents, err := step.findDependents(cl, dClient, []unstructured.Unstructured{*ownerObj}, testNamespace)
+	assert.NoError(t, err)
+	if assert.Len(t, dependents, 1) {
+		assert.Equal(t, "owned", dependents[0].GetName())
+	}
+	assert.ElementsMatch(t, []string{"Pod:world", "Secret:world", "ClusterRole:"}, cl.listed)
+}
+
+func TestValidateDeletions(t *testing.T) {
+	background, unknown, grace := metav1.DeletePropagationBackground, metav1.DeletionPropagation("Cascade"), int64(-1)
+	disabled := false
+
+	for _, test := range []struct {
+		name        string
+		deletion    harness.Deletion
+		expectedErr string
+	}{
+		{
+			name:     "valid deletion",
+			deletion: harness.Deletion{ObjectReference: podReference("", nil), LabelSelector: "app in (web, db)", FieldSelector: "status.phase=Succeeded", PropagationPolicy: &background},
+		},
+		{
+			name:        "selector with a name",
+			deletion:    harness.Deletion{ObjectReference: podReference("hello", nil), LabelSelector: "app=web"},
+			expectedErr: "invalid delete of Pod hello: selectors are only supported without a name",
+		},
+		{
+			name:        "invalid selector",
+			deletion:    harness.Deletion{ObjectReference: podReference("", map[string]string{"app": "web"}), FieldSelector: "status.phase"},
+			expectedErr: `invalid delete of Pod selected by labels map[app:web]: parsing field selector "status.phase": invalid selector: 'status.phase'; can't understand 'status.phase'`,
+		},
+		{
+			name:        "unknown propagation policy",
+			deletion:    harness.Deletion{ObjectReference: podReference("hello", nil), PropagationPolicy: &unknown},
+			expectedErr: `invalid delete of Pod hello: unknown propagation policy "Cascade", expected one of Foreground, Background or Orphan`,
+		},
+		{
+			name:        "negative grace period",
+			deletion:    harness.Deletion{ObjectReference: podReference("hello", nil), GracePeriodSeconds: &grace},
+			expectedErr: "invalid delete of Pod hello: gracePeriodSeconds must not be negative",
+		},
+		{
+			name:        "dependents without waiting",
+			deletion:    harness.Deletion{ObjectReference: podReference("hello", nil), Wait: &disabled, WaitForDependents: true},
+			expectedErr: "invalid delete of Pod hello: waitForDependents requires wait",
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			err := validateDeletions([]harness.Deletion{test.deletion})
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.expectedErr)
+			}
+		})
+	}
+}
+
+func podReference(name string, labels map[string]string) harness.ObjectReference {
+	return harness.ObjectReference{
+		ObjectReference: corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Name: name},
+		Labels:          labels,
+	}
+}
+
+// listDiscoveryClient returns a fake discovery client serving pods which can be listed, unlike the resources of
+// testutils.FakeDiscoveryClient.
+func listDiscoveryClient() discovery.DiscoveryInterface {
+	return &fakediscovery.FakeDiscovery{
+		Fake: &coretesting.Fake{
+			Resources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "v1",
+					APIResources: []metav1.APIResource{
+						{Name: "pods", Namespaced: true, Kind: "Pod", Verbs: metav1.Verbs{"get", "list", "delete"}},
+						{Name: "pods/log", Namespaced: true, Kind: "Pod", Verbs: metav1.Verbs{"get"}},
+					},
+				},
+			},
+		},
+	}
+}
+
+// deleteClient records the options of the delete requests.
+type deleteClient struct {
+	client.Client
+	options []*client.DeleteOptions
+}
+
+func (c *deleteClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	c.options = append(c.options, (&client.DeleteOptions{}).ApplyOptions(opts))
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+// listClient records the lists, by kind and namespace, and does not allow listing secrets.
+type listClient struct {
+	client.Client
+	listed []string
+}
+
+func (c *listClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOptions := (&client.ListOptions{}).ApplyOptions(opts)
+	kind := strings.TrimSuffix(list.GetObjectKind().GroupVersionKind().Kind, "List")
+	c.listed = append(c.listed, fmt.Sprintf("%s:%s", kind, listOptions.Namespace))
+	if kind == "Secret" {
+		return k8serrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "", errors.New("not allowed"))
+	}
+	return c.Client.List(ctx, list, opts...)
+}
@@ -0,0 +1,128 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+type dryRun struct {
+	objects []client.Object
+	// expected are the objects expected to be returned by the API server.
+	expected      []client.Object
+	shouldFail    bool
+	expectedError *harness.ExpectedError
+	warnings      *harness.Warnings
+}
+
+// DryRun submits all objects in the DryRuns list with server-side dry-run and checks the admission results.
+func (s *Step) DryRun(namespace string) []error {
+	cl, err := s.Client(false)
+	if err != nil {
+		return []error{err}
+	}
+
+	dClient, err := s.DiscoveryClient()
+	if err != nil {
+		return []error{err}
+	}
+
+	errs := []error{}
+
+	for _, d := range s.DryRuns {
+		shouldFail := d.shouldFail || d.expectedError != nil
+		results := []unstructured.Unstructured{}
+
+		for _, obj := range d.objects {
+			result, warnings, err := doDryRun(s.Logger, s.Timeout, dClient, cl, obj, namespace)
+			if err != nil && !shouldFail {
+				errs = append(errs, err)
+			}
+			if err != nil && d.expectedError != nil {
+				if err := d.expectedError.ValidateError(err); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			if err == nil && shouldFail {
+				errs = append(errs, fmt.Errorf("an error was expected when submitting %s with dry-run but didn't happen", testutils.ResourceID(obj)))
+			}
+			if d.warnings != nil {
+				if err := d.warnings.ValidateWarnings(warnings); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", testutils.ResourceID(obj), err))
+				}
+			}
+			if result != nil {
+				results = append(results, *result)
+			}
+		}
+
+		for _, expected := range d.expected {
+			errs = append(errs, checkDryRunResult(dClient, expected, results, namespace)...)
+		}
+	}
+
+	return errs
+}
+
+// doDryRun submits obj with server-side dry-run and returns the object and the warnings returned by the API server.
+func doDryRun(logger testutils.Logger, timeout int, dClient discovery.DiscoveryInterface, cl client.Client, obj client.Object, namespace string) (*unstructured.Unstructured, []string, error) {
+	if _, _, err := testutils.Namespaced(dClient, obj, namespace); err != nil {
+		return nil, nil, err
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
+
+	var result *unstructured.Unstructured
+	warnings, err := testutils.CaptureWarnings(ctx, cl, func(ctx context.Context) (err error) {
+		result, _, err = testutils.DryRunCreateOrUpdate(ctx, cl, obj)
+		return err
+	})
+	for _, warning := range warnings {
+		logger.Logf("%s: warning: %s", testutils.ResourceID(obj), warning)
+	}
+	if err != nil {
+		logger.Log(testutils.ResourceID(obj), "denied (dry-run)")
+		return nil, warnings, fmt.Errorf("submitting %s with dry-run: %w", testutils.ResourceID(obj), err)
+	}
+
+	logger.Log(testutils.ResourceID(obj), "admitted (dry-run)")
+	return result, warnings, nil
+}
+
+// checkDryRunResult compares expected with the results of the same kind, name and namespace.
+func checkDryRunResult(dClient discovery.DiscoveryInterface, expected client.Object, results []unstructured.Unstructured, namespace string) []error {
+	if _, _, err := testutils.Namespaced(dClient, expected, namespace); err != nil {
+		return []error{err}
+	}
+
+	gvk := expected.GetObjectKind().GroupVersionKind()
+
+	candidates := []unstructured.Unstructured{}
+	for _, result := range results {
+		if result.GroupVersionKind() != gvk || result.GetNamespace() != expected.GetNamespace() {
+			continue
+		}
+		if expected.GetName() != "" && result.GetName() != expected.GetName() {
+			continue
+		}
+		candidates = append(candidates, result)
+	}
+
+	if len(candidates) == 0 {
+		return []error{fmt.Errorf("no dry-run result matched %s", testutils.ResourceID(expected))}
+	}
+
+	return compareResources(expected, candidates, testutils.DefaultStrategyFactory())
+}
@@ -0,0 +1,159 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+func TestStepDryRun(t *testing.T) {
+	denied := &k8serrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    400,
+		Reason:  metav1.StatusReasonBadRequest,
+		Message: `admission webhook "validate.kyverno.svc-fail" denied the request: label app is required`,
+	}}
+
+	for _, test := range []struct {
+		name         string
+		dryRun       dryRun
+		expectedErrs []string
+	}{
+		{
+			name: "mutated object",
+			dryRun: dryRun{
+				objects:  []client.Object{testutils.WithLabels(t, testutils.NewPod("hello", ""), map[string]string{"app": "hello"})},
+				expected: []client.Object{testutils.WithLabels(t, testutils.NewPod("hello", ""), map[string]string{"app": "hello", "mutated": "true"})},
+				warnings: &harness.Warnings{Forbidden: []harness.ExpectedOutput{{MatchType: harness.MatchContains, ExpectedValue: "deprecated"}}},
+			},
+		},
+		{
+			name: "object not mutated as expected",
+			dryRun: dryRun{
+				objects:  []client.Object{testutils.WithLabels(t, testutils.NewPod("hello", ""), map[string]string{"app": "hello"})},
+				expected: []client.Object{testutils.WithLabels(t, testutils.NewPod("hello", ""), map[string]string{"mutated": "false"})},
+			},
+			expectedErrs: []string{"resource Pod:world/hello: /metadata/labels/mutated: value mismatch, expected: false != actual: true"},
+		},
+		{
+			name: "no result matches",
+			dryRun: dryRun{
+				objects:  []client.Object{testutils.WithLabels(t, testutils.NewPod("hello", ""), map[string]string{"app": "hello"})},
+				expected: []client.Object{testutils.NewPod("other", "")},
+			},
+			expectedErrs: []string{"no dry-run result matched Pod:world/other"},
+		},
+		{
+			name: "expected denial",
+			dryRun: dryRun{
+				objects: []client.Object{testutils.NewPod("hello", "")},
+				expectedError: &harness.ExpectedError{
+					Reason:  metav1.StatusReasonBadRequest,
+					Message: &harness.ExpectedOutput{MatchType: harness.MatchRegex, ExpectedValue: `^admission webhook "validate\.kyverno\.svc-fail" denied`},
+				},
+			},
+		},
+		{
+			name: "unexpected denial",
+			dryRun: dryRun{
+				objects: []client.Object{testutils.NewPod("hello", "")},
+			},
+			expectedErrs: []string{fmt.Sprintf("submitting Pod:world/hello with dry-run: %s", denied)},
+		},
+		{
+			name: "expected denial did not happen",
+			dryRun: dryRun{
+				objects:    []client.Object{testutils.WithLabels(t, testutils.NewPod("hello", ""), map[string]string{"app": "hello"})},
+				shouldFail: true,
+			},
+			expectedErrs: []string{"an error was expected when submitting Pod:world/hello with dry-run but didn't happen"},
+		},
+		{
+			name: "expected warning",
+			dryRun: dryRun{
+				objects:  []client.Object{testutils.WithLabels(t, testutils.NewPod("hello", ""), map[string]string{"app": "hello", "deprecated": "true"})},
+				warnings: &harness.Warnings{Expected: []harness.ExpectedOutput{{MatchType: harness.MatchContains, ExpectedValue: "label deprecated"}}},
+			},
+		},
+		{
+			name: "missing warning",
+			dryRun: dryRun{
+				objects:  []client.Object{testutils.WithLabels(t, testutils.NewPod("hello", ""), map[string]string{"app": "hello"})},
+				warnings: &harness.Warnings{Expected: []harness.ExpectedOutput{{MatchType: harness.MatchContains, ExpectedValue: "label deprecated"}}},
+			},
+			expectedErrs: []string{`Pod:world/hello: unexpected warnings []: expected a warning matching Contains "label deprecated"`},
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			cl := &admissionClient{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), denied: denied}
+
+			step := Step{
+				Logger:          testutils.NewTestLogger(t, ""),
+				DryRuns:         []dryRun{test.dryRun},
+				Client:          func(bool) (client.Client, error) { return cl, nil },
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+			}
+
+			errs := []string{}
+			for _, err := range step.DryRun(testNamespace) {
+				// skip the diffs of mismatching results
+				if !strings.HasPrefix(err.Error(), "--- ") {
+					errs = append(errs, err.Error())
+				}
+			}
+			assert.ElementsMatch(t, test.expectedErrs, errs)
+
+			// nothing is persisted
+			actual := testutils.NewPod("hello", testNamespace)
+			assert.True(t, k8serrors.IsNotFound(cl.Get(context.TODO(), testutils.ObjectKey(actual), actual)))
+		})
+	}
+}
+
+// admissionClient emulates admission webhooks for objects created with dry-run: objects without an app label are
+// denied, the others are labeled as mutated and objects with a deprecated label cause a warning.
+type admissionClient struct {
+	client.Client
+	denied error
+
+	capturing bool
+	warnings  []string
+}
+
+func (c *admissionClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.Client.Create(ctx, obj, opts...); err != nil {
+		return err
+	}
+
+	labels := obj.GetLabels()
+	if labels["app"] == "" {
+		return c.denied
+	}
+	if labels["deprecated"] != "" && c.capturing {
+		c.warnings = append(c.warnings, "label deprecated is deprecated")
+	}
+	labels["mutated"] = "true"
+	obj.SetLabels(labels)
+	return nil
+}
+
+func (c *admissionClient) CaptureWarnings(ctx context.Context, fn func(ctx context.Context) error) ([]string, error) {
+	c.capturing, c.warnings = true, nil
+	defer func() { c.capturing = false }()
+	err := fn(ctx)
+	return c.warnings, err
+}
@@ -20,6 +20,7 @@ import (
 	volumetypes "github.com/docker/docker/api/types/volume"
 	docker "github.com/docker/docker/client"
 	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/rest"
@@ -46,10 +47,12 @@ type Harness struct {
 	config        *rest.Config
 	docker        testutils.DockerClient
 	client        client.Client
-	dclient       discovery.DiscoveryInterface
+	dclient       discovery.CachedDiscoveryInterface
+	mapper        meta.ResettableRESTMapper
 	env           *envtest.Environment
 	kind          *kind
 	tempPath      string
+	retry         *retryPolicy
 	clientLock    sync.Mutex
 	configLock    sync.Mutex
 	stopping      bool
@@ -86,6 +89,10 @@ func (h *Harness) LoadTests(dir string, shouldSkip func(string) bool) ([]*Case,
 				Dir:                filepath.Join(dir, file.Name()),
 				SkipDelete:         h.TestSuite.SkipDelete,
 				Suppress:           h.TestSuite.Suppress,
+				Variables:          h.TestSuite.Variables,
+				Templating:         h.TestSuite.Templating,
+				ServerSideApply:    h.TestSuite.ServerSideApply,
+				Retry:              h.retry,
 			})
 
 			subDirs, err := h.LoadTests(path.Join(dir, file.Name()), shouldSkip)
@@ -319,13 +326,20 @@ func (h *Harness) Client(forceNew bool) (client.Client, error) {
 		return nil, err
 	}
 
+	if err := h.initDiscovery(cfg); err != nil {
+		return nil, err
+	}
+
 	h.client, err = testutils.NewRetryClient(cfg, client.Options{
 		Scheme: testutils.Scheme(),
+		Mapper: h.mapper,
 	})
 	return h.client, err
 }
 
 // DiscoveryClient returns the current Kubernetes discovery client for the test harness.
+// The discovery information is cached and shared with the REST mapper of the clients of the harness, it is
+// refreshed when they encounter kinds which are not known yet.
 func (h *Harness) DiscoveryClient() (discovery.DiscoveryInterface, error) {
 	h.clientLock.Lock()
 	defer h.clientLock.Unlock()
@@ -339,8 +353,26 @@ func (h *Harness) DiscoveryClient() (discovery.DiscoveryInterface, error) {
 		return nil, err
 	}
 
-	h.dclient, err = discovery.NewDiscoveryClientForConfig(cfg)
-	return h.dclient, err
+	if err := h.initDiscovery(cfg); err != nil {
+		return nil, err
+	}
+	return h.dclient, nil
+}
+
+// initDiscovery initializes the cached discovery client and REST mapper of the harness, h.clientLock must be held.
+func (h *Harness) initDiscovery(cfg *rest.Config) error {
+	if h.dclient != nil {
+		return nil
+	}
+
+	dclient, err := testutils.NewCachedDiscoveryClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	h.dclient = dclient
+	h.mapper = testutils.NewRESTMapper(dclient)
+	return nil
 }
 
 // DockerClient returns the Docker client to use for the test harness.
@@ -417,6 +449,7 @@ func (h *Harness) RunTests() {
 
 				test.Client = h.Client
 				test.DiscoveryClient = h.DiscoveryClient
+				test.Config = h.Config
 
 				name := test.Name
 				if h.TestSuite.FullName {
@@ -509,6 +542,12 @@ func (h *Harness) Setup() {
 	h.report = report.NewSuiteCollection(h.TestSuite.Name)
 	h.T.Log("starting setup")
 
+	retry, err := compileRetryPolicy(h.TestSuite.Retry)
+	if err != nil {
+		h.fatal(fmt.Errorf("fatal error in test suite: invalid retry: %v", err))
+	}
+	h.retry = retry
+
 	cl, err := h.Client(false)
 	if err != nil {
 		h.fatal(fmt.Errorf("fatal error getting client: %v", err))
@@ -536,11 +575,8 @@ func (h *Harness) Setup() {
 		h.fatal(fmt.Errorf("fatal error waiting for crds: %v", err))
 	}
 
-	// Create a new client to bust the client's CRD cache.
-	cl, err = h.Client(true)
-	if err != nil {
-		h.fatal(fmt.Errorf("fatal error getting client after crd update: %v", err))
-	}
+	// Refresh the client's REST mapping to map the kinds of the CRDs.
+	testutils.RefreshMapping(cl)
 
 	// Install required manifests.
 	for _, manifestDir := range h.TestSuite.ManifestDirs {
@@ -548,7 +584,7 @@ func (h *Harness) Setup() {
 			h.fatal(fmt.Errorf("fatal error installing manifests: %v", err))
 		}
 	}
-	bgs, err := testutils.RunCommands(context.TODO(), h.GetLogger(), "default", h.TestSuite.Commands, "", h.TestSuite.Timeout, "")
+	bgs, err := testutils.RunCommands(context.TODO(), h.GetLogger(), "default", h.TestSuite.Commands, "", h.TestSuite.Timeout, "", nil)
 	// assign any background processes first for cleanup in case of any errors
 	h.bgProcesses = append(h.bgProcesses, bgs...)
 	if err != nil {
@@ -0,0 +1,88 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+// impersonationConfig converts i to the impersonation configuration of a client, service accounts without a
+// namespace belong to namespace.
+func impersonationConfig(i *harness.Impersonation, namespace string) rest.ImpersonationConfig {
+	if i.ServiceAccount == "" {
+		return rest.ImpersonationConfig{UserName: i.User, Groups: i.Groups}
+	}
+
+	userName, groups := serviceAccountSubject(i.ServiceAccount, namespace)
+
+	// the API server only adds the groups of the service account if no group is impersonated
+	if len(i.Groups) == 0 {
+		groups = nil
+	} else {
+		groups = append(groups, i.Groups...)
+	}
+
+	return rest.ImpersonationConfig{UserName: userName, Groups: groups}
+}
+
+// serviceAccountSubject returns the user name and the groups of the service account sa, either a name in namespace
+// or namespace/name.
+func serviceAccountSubject(sa, namespace string) (string, []string) {
+	name := sa
+	if ns, n, ok := strings.Cut(sa, "/"); ok {
+		namespace, name = ns, n
+	}
+	return fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name), []string{"system:serviceaccounts", "system:serviceaccounts:" + namespace}
+}
+
+// impersonatingKubeconfig writes a kubeconfig impersonating the subject of step to a temporary directory and returns
+// its path. It is based on the kubeconfig of the step, if any, or on the configuration of the test case.
+func (t *Case) impersonatingKubeconfig(test *testing.T, step *Step, namespace string) (string, error) {
+	var cfg *rest.Config
+	var err error
+	switch {
+	case step.Kubeconfig != "":
+		cfg, err = clientcmd.BuildConfigFromFlags("", step.Kubeconfig)
+	case t.Config != nil:
+		cfg, err = t.Config()
+	default:
+		err = errors.New("no client configuration to impersonate")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	cfg = rest.CopyConfig(cfg)
+	cfg.Impersonate = impersonationConfig(step.Impersonate, namespace)
+
+	path := filepath.Join(test.TempDir(), "kubeconfig")
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if err := testutils.Kubeconfig(cfg, f); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func validateImpersonation(i *harness.Impersonation) error {
+	if (i.User == "") == (i.ServiceAccount == "") {
+		return errors.New("exactly one of user and serviceAccount must be set")
+	}
+	if ns, name, ok := strings.Cut(i.ServiceAccount, "/"); ok && (ns == "" || name == "" || strings.Contains(name, "/")) {
+		return fmt.Errorf("invalid service account %q, expected name or namespace/name", i.ServiceAccount)
+	}
+	return nil
+}
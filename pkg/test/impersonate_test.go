@@ -0,0 +1,78 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+)
+
+func TestImpersonationConfig(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		impersonation harness.Impersonation
+		expected      rest.ImpersonationConfig
+	}{
+		{
+			name:          "user",
+			impersonation: harness.Impersonation{User: "tenant-a", Groups: []string{"tenants"}},
+			expected:      rest.ImpersonationConfig{UserName: "tenant-a", Groups: []string{"tenants"}},
+		},
+		{
+			name:          "service account of the test namespace",
+			impersonation: harness.Impersonation{ServiceAccount: "deployer"},
+			expected:      rest.ImpersonationConfig{UserName: "system:serviceaccount:world:deployer"},
+		},
+		{
+			name:          "service account of another namespace",
+			impersonation: harness.Impersonation{ServiceAccount: "tenant-b/deployer"},
+			expected:      rest.ImpersonationConfig{UserName: "system:serviceaccount:tenant-b:deployer"},
+		},
+		{
+			name:          "service account with groups",
+			impersonation: harness.Impersonation{ServiceAccount: "deployer", Groups: []string{"tenants"}},
+			expected: rest.ImpersonationConfig{
+				UserName: "system:serviceaccount:world:deployer",
+				Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:world", "tenants"},
+			},
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, impersonationConfig(&test.impersonation, testNamespace))
+		})
+	}
+}
+
+func TestImpersonatingKubeconfig(t *testing.T) {
+	c := &Case{
+		Config: func() (*rest.Config, error) {
+			return &rest.Config{Host: "https://127.0.0.1:6443", BearerToken: "token"}, nil
+		},
+	}
+	step := &Step{Impersonate: &harness.Impersonation{ServiceAccount: "deployer"}}
+
+	kubeconfig, err := c.impersonatingKubeconfig(t, step, testNamespace)
+	assert.NoError(t, err)
+
+	cfg, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://127.0.0.1:6443", cfg.Host)
+	assert.Equal(t, "token", cfg.BearerToken)
+	assert.Equal(t, "system:serviceaccount:world:deployer", cfg.Impersonate.UserName)
+
+	_, err = (&Case{}).impersonatingKubeconfig(t, step, testNamespace)
+	assert.EqualError(t, err, "no client configuration to impersonate")
+}
+
+func TestValidateImpersonation(t *testing.T) {
+	assert.NoError(t, validateImpersonation(&harness.Impersonation{User: "tenant-a", Groups: []string{"tenants"}}))
+	assert.NoError(t, validateImpersonation(&harness.Impersonation{ServiceAccount: "tenant-b/deployer"}))
+	assert.EqualError(t, validateImpersonation(&harness.Impersonation{Groups: []string{"tenants"}}), "exactly one of user and serviceAccount must be set")
+	assert.EqualError(t, validateImpersonation(&harness.Impersonation{User: "tenant-a", ServiceAccount: "deployer"}), "exactly one of user and serviceAccount must be set")
+	assert.EqualError(t, validateImpersonation(&harness.Impersonation{ServiceAccount: "tenant-b/"}), `invalid service account "tenant-b/", expected name or namespace/name`)
+}
@@ -0,0 +1,178 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+// preservedOrder is the order in which the objects of a step preserving the order of its files are applied, by
+// kind: only the kinds all other objects may depend on come first.
+var preservedOrder = map[schema.GroupKind]int{
+	{Kind: "Namespace"}: 0,
+
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}: 1,
+}
+
+// kindOrder is the order in which the objects of a step are applied by default, by kind. Admission policies come
+// right after the CustomResourceDefinitions, so that they apply to all objects of the step. Webhook configurations
+// come last with custom resources and all other kinds, as their webhooks are usually served by the workloads of the
+// step.
+var kindOrder = map[schema.GroupKind]int{
+	{Kind: "Namespace"}: 0,
+
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}: 1,
+
+	{Group: "kyverno.io", Kind: "ClusterPolicy"}:                                      2,
+	{Group: "kyverno.io", Kind: "Policy"}:                                             2,
+	{Group: "kyverno.io", Kind: "PolicyException"}:                                    2,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicy"}:        2,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicyBinding"}: 2,
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingAdmissionPolicy"}:          2,
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingAdmissionPolicyBinding"}:   2,
+
+	{Kind: "ServiceAccount"}:                                         3,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:        3,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}: 3,
+	{Group: "rbac.authorization.k8s.io", Kind: "Role"}:               3,
+	{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}:        3,
+
+	{Kind: "ConfigMap"}:                                 4,
+	{Kind: "Secret"}:                                    4,
+	{Kind: "ResourceQuota"}:                             4,
+	{Kind: "LimitRange"}:                                4,
+	{Kind: "PersistentVolume"}:                          4,
+	{Kind: "PersistentVolumeClaim"}:                     4,
+	{Group: "storage.k8s.io", Kind: "StorageClass"}:     4,
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"}: 4,
+
+	{Kind: "Service"}:                    5,
+	{Kind: "Pod"}:                        5,
+	{Kind: "ReplicationController"}:      5,
+	{Group: "apps", Kind: "Deployment"}:  5,
+	{Group: "apps", Kind: "StatefulSet"}: 5,
+	{Group: "apps", Kind: "DaemonSet"}:   5,
+	{Group: "apps", Kind: "ReplicaSet"}:  5,
+	{Group: "batch", Kind: "Job"}:        5,
+	{Group: "batch", Kind: "CronJob"}:    5,
+}
+
+// groupOrder is the order of the API groups of which all kinds are ordered alike by kindOrder, e.g. the groups of
+// admission policies.
+var groupOrder = map[string]int{
+	"policies.kyverno.io": 2,
+}
+
+// sortApplies returns the applies sorted by the order of their kinds, by preservedOrder if preserveOrder is true and
+// by kindOrder otherwise. Applies of the same order keep their order.
+func sortApplies(applies []apply, preserveOrder bool) []apply {
+	orders := kindOrder
+	if preserveOrder {
+		orders = preservedOrder
+	}
+	order := func(a apply) int {
+		gk := a.object.GetObjectKind().GroupVersionKind().GroupKind()
+		if o, ok := orders[gk]; ok {
+			return o
+		}
+		if o, ok := groupOrder[gk.Group]; ok && !preserveOrder {
+			return o
+		}
+		return len(orders)
+	}
+
+	sorted := append([]apply{}, applies...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return order(sorted[i]) < order(sorted[j])
+	})
+	return sorted
+}
+
+func isCRD(obj client.Object) bool {
+	return obj.GetObjectKind().GroupVersionKind().GroupKind() == schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+}
+
+// establishCRDs waits until the crds are established and the REST mapper of cl maps their kinds.
+func (s *Step) establishCRDs(cl client.Client, crds []client.Object) error {
+	ctx := context.Background()
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(s.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	for _, crd := range crds {
+		var reason string
+		err := wait.PollImmediateUntilWithContext(ctx, readyPollInterval, func(ctx context.Context) (bool, error) {
+			actual := &unstructured.Unstructured{}
+			actual.SetGroupVersionKind(crd.GetObjectKind().GroupVersionKind())
+			if err := cl.Get(ctx, testutils.ObjectKey(crd), actual); err != nil {
+				reason = err.Error()
+				return false, nil
+			}
+			var established bool
+			established, reason = testutils.Readiness(actual)
+			return established, nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s is not established: %s", testutils.ResourceID(crd), reason)
+		}
+		s.Logger.Log(testutils.ResourceID(crd), "established")
+	}
+
+	for _, crd := range crds {
+		gk, versions := crdKinds(crd)
+		for _, version := range versions {
+			var mappingErr error
+			if err := wait.PollImmediateUntilWithContext(ctx, readyPollInterval, func(ctx context.Context) (bool, error) {
+				mappingErr = testutils.WithMappingRefresh(cl, func() error {
+					_, err := cl.RESTMapper().RESTMapping(gk, version)
+					return err
+				})
+				return mappingErr == nil, nil
+			}); err != nil {
+				return fmt.Errorf("%s is not served: %w", testutils.ResourceID(crd), mappingErr)
+			}
+		}
+	}
+
+	return nil
+}
+
+// crdKinds returns the kind defined by crd and its served versions.
+func crdKinds(crd client.Object) (schema.GroupKind, []string) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(crd)
+	if err != nil {
+		return schema.GroupKind{}, nil
+	}
+
+	group, _, _ := unstructured.NestedString(content, "spec", "group")
+	kind, _, _ := unstructured.NestedString(content, "spec", "names", "kind")
+
+	versions := []string{}
+	specVersions, _, _ := unstructured.NestedSlice(content, "spec", "versions")
+	for _, v := range specVersions {
+		v, ok := v.(map[string]interface{})
+		if !ok || v["served"] != true {
+			continue
+		}
+		if name, ok := v["name"].(string); ok {
+			versions = append(versions, name)
+		}
+	}
+	// apiextensions.k8s.io/v1beta1 CRDs may define a single version
+	if version, found, _ := unstructured.NestedString(content, "spec", "version"); found && len(specVersions) == 0 {
+		versions = append(versions, version)
+	}
+
+	return schema.GroupKind{Group: group, Kind: kind}, versions
+}
@@ -0,0 +1,206 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+func TestSortApplies(t *testing.T) {
+	applies := []apply{
+		{object: testutils.NewResource("example.com/v1", "Database", "db", "")},
+		{object: testutils.NewResource("apps/v1", "Deployment", "app", "")},
+		{object: testutils.NewResource("v1", "ConfigMap", "config", "")},
+		{object: testutils.NewResource("kyverno.io/v1", "ClusterPolicy", "policy", "")},
+		{object: testutils.NewResource("rbac.authorization.k8s.io/v1", "RoleBinding", "binding", "")},
+		{object: testutils.NewResource("example.com/v1", "Role", "custom-role", "")},
+		{object: testutils.NewResource("apiextensions.k8s.io/v1", "CustomResourceDefinition", "databases.example.com", "")},
+		{object: testutils.NewResource("v1", "Namespace", "tenant", "")},
+		{object: testutils.NewResource("v1", "ServiceAccount", "deployer", "")},
+		{object: testutils.NewResource("v1", "Pod", "pod", "")},
+		{object: testutils.NewResource("policies.kyverno.io/v1alpha1", "ValidatingPolicy", "validating-policy", "")},
+		{object: testutils.NewResource("admissionregistration.k8s.io/v1", "ValidatingWebhookConfiguration", "webhook", "")},
+	}
+
+	for _, test := range []struct {
+		name          string
+		preserveOrder bool
+		expected      []string
+	}{
+		{
+			name: "by kind",
+			expected: []string{"tenant", "databases.example.com", "policy", "validating-policy", "binding", "deployer", "config", "app", "pod",
+				"db", "custom-role", "webhook"},
+		},
+		{
+			name:          "namespaces and CRDs first",
+			preserveOrder: true,
+			expected: []string{"tenant", "databases.example.com", "db", "app", "config", "policy", "binding", "custom-role", "deployer", "pod",
+				"validating-policy", "webhook"},
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			names := []string{}
+			for _, a := range sortApplies(applies, test.preserveOrder) {
+				names = append(names, a.object.GetName())
+			}
+			assert.Equal(t, test.expected, names)
+			assert.Equal(t, "db", applies[0].object.GetName())
+		})
+	}
+}
+
+func TestStepCreatePolicyBeforeResource(t *testing.T) {
+	policyDiscovery := testutils.FakeDiscoveryClient().(*fakediscovery.FakeDiscovery)
+	policyDiscovery.Resources = append(policyDiscovery.Resources, &metav1.APIResourceList{
+		GroupVersion: "kyverno.io/v1",
+		APIResources: []metav1.APIResource{{Name: "clusterpolicies", Namespaced: false, Kind: "ClusterPolicy"}},
+	})
+
+	for _, test := range []struct {
+		name          string
+		preserveOrder bool
+		expectedErrs  []error
+	}{
+		{
+			name:         "by kind",
+			expectedErrs: []error{},
+		},
+		{
+			// the pod precedes the policy in the files, it is created before the policy
+			name:          "order of the files",
+			preserveOrder: true,
+			expectedErrs:  []error{errors.New("an error was expected but didn't happen")},
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			cl := &policyClient{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()}
+
+			step := Step{
+				Logger:  testutils.NewTestLogger(t, ""),
+				Timeout: 1,
+				Step:    &harness.TestStep{PreserveOrder: test.preserveOrder},
+				Apply: []apply{
+					{object: testutils.NewPod("hello", ""), shouldFail: true},
+					{object: testutils.NewResource("kyverno.io/v1", "ClusterPolicy", "deny-pods", "")},
+				},
+				Client:          func(bool) (client.Client, error) { return cl, nil },
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return policyDiscovery, nil },
+				SkipDelete:      true,
+			}
+
+			assert.Equal(t, test.expectedErrs, step.Create(t, testNamespace))
+		})
+	}
+}
+
+// policyClient denies the creation of pods once a cluster policy exists, like an admission controller.
+type policyClient struct {
+	client.Client
+}
+
+func (c *policyClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if obj.GetObjectKind().GroupVersionKind().Kind == "Pod" {
+		policies := &unstructured.UnstructuredList{}
+		policies.SetGroupVersionKind(schema.GroupVersionKind{Group: "kyverno.io", Version: "v1", Kind: "ClusterPolicyList"})
+		if err := c.Client.List(ctx, policies); err != nil {
+			return err
+		}
+		if len(policies.Items) > 0 {
+			return k8serrors.NewForbidden(schema.GroupResource{Resource: "pods"}, obj.GetName(), errors.New("denied by deny-pods"))
+		}
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func TestStepCreateCRD(t *testing.T) {
+	database := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Database"}
+
+	crd := testutils.NewResource("apiextensions.k8s.io/v1", "CustomResourceDefinition", "databases.example.com", "")
+	assert.NoError(t, unstructured.SetNestedField(crd.Object, "example.com", "spec", "group"))
+	assert.NoError(t, unstructured.SetNestedField(crd.Object, "Database", "spec", "names", "kind"))
+	assert.NoError(t, unstructured.SetNestedSlice(crd.Object, []interface{}{
+		map[string]interface{}{"name": "v1", "served": true, "storage": true},
+	}, "spec", "versions"))
+
+	fakeMapper := meta.NewDefaultRESTMapper(nil)
+	fakeMapper.Add(database, meta.RESTScopeNamespace)
+	// the REST mapping of the client was loaded before the CRD was created
+	cl := &mappingClient{
+		Client:      fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(),
+		mapper:      meta.NewDefaultRESTMapper(nil),
+		freshMapper: fakeMapper,
+	}
+
+	step := Step{
+		Logger:  testutils.NewTestLogger(t, ""),
+		Timeout: 1,
+		Apply: []apply{
+			{object: testutils.NewResource("example.com/v1", "Database", "hello", testNamespace)},
+			{object: crd},
+		},
+		Client:          func(bool) (client.Client, error) { return cl, nil },
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+		SkipDelete:      true,
+	}
+
+	assert.Equal(t, []error{}, step.Create(t, testNamespace))
+	assert.Equal(t, 1, cl.refreshes)
+
+	actual := &unstructured.Unstructured{}
+	actual.SetGroupVersionKind(database)
+	assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: "hello"}, actual))
+}
+
+// mappingClient emulates the API server and the REST mapping of the client: objects of kinds unknown to the mapper
+// are rejected, CRDs are established as soon as they are created and refreshing the mapping loads freshMapper.
+type mappingClient struct {
+	client.Client
+	mapper      meta.RESTMapper
+	freshMapper meta.RESTMapper
+	refreshes   int
+}
+
+func (c *mappingClient) RESTMapper() meta.RESTMapper {
+	return c.mapper
+}
+
+func (c *mappingClient) RefreshMapping() {
+	c.mapper = c.freshMapper
+	c.refreshes++
+}
+
+func (c *mappingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+
+	if isCRD(obj) {
+		if err := unstructured.SetNestedSlice(obj.(*unstructured.Unstructured).Object, []interface{}{
+			map[string]interface{}{"type": "Established", "status": "True"},
+		}, "status", "conditions"); err != nil {
+			return err
+		}
+	} else if _, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+		return err
+	}
+
+	return c.Client.Create(ctx, obj, opts...)
+}
@@ -0,0 +1,205 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+// CheckOwnership checks that the dependents of each check are owned by its owner, so that the deletion of the owner
+// cascades to them. The owner references must match the uid of the owner, dependents referencing an earlier owner
+// with the same name are reported as such.
+func (s *Step) CheckOwnership(namespace string, checks []harness.OwnershipCheck) []error {
+	if len(checks) == 0 {
+		return nil
+	}
+
+	cl, err := s.Client(false)
+	if err != nil {
+		return []error{err}
+	}
+
+	dClient, err := s.DiscoveryClient()
+	if err != nil {
+		return []error{err}
+	}
+
+	errs := []error{}
+
+	for _, check := range checks {
+		owners, err := getReferenced(cl, dClient, check.Owner, namespace, nil)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(owners) != 1 {
+			errs = append(errs, ownerNotMatched(check.Owner, owners))
+			continue
+		}
+		owner := &owners[0]
+
+		for _, ref := range check.Dependents {
+			dependents, err := getReferenced(cl, dClient, ref, namespace, nil)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if len(dependents) == 0 {
+				errs = append(errs, fmt.Errorf("no %s dependents of %s matched %s", ref.Kind, testutils.ResourceID(owner), describeReference(ref)))
+				continue
+			}
+
+			for i := range dependents {
+				if err := checkOwnerReference(owner, &dependents[i], check); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+
+	return errs
+}
+
+// CascadeOwnership deletes the owners of the checks which cascade and waits until their dependents are deleted by the
+// garbage collector. The checks must have passed.
+func (s *Step) CascadeOwnership(namespace string, checks []harness.OwnershipCheck) []error {
+	cascading := []harness.OwnershipCheck{}
+	for _, check := range checks {
+		if check.Cascade {
+			cascading = append(cascading, check)
+		}
+	}
+	if len(cascading) == 0 {
+		return nil
+	}
+
+	cl, err := s.Client(false)
+	if err != nil {
+		return []error{err}
+	}
+
+	dClient, err := s.DiscoveryClient()
+	if err != nil {
+		return []error{err}
+	}
+
+	errs := []error{}
+
+	for _, check := range cascading {
+		owners, err := getReferenced(cl, dClient, check.Owner, namespace, nil)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(owners) != 1 {
+			errs = append(errs, ownerNotMatched(check.Owner, owners))
+			continue
+		}
+		owner := &owners[0]
+
+		// the dependents are looked up before their owner is gone
+		dependents := []unstructured.Unstructured{}
+		for _, ref := range check.Dependents {
+			objs, err := getReferenced(cl, dClient, ref, namespace, nil)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			dependents = append(dependents, objs...)
+		}
+
+		err = s.retrying(testutils.ResourceID(owner), func(ctx context.Context) error {
+			return cl.Delete(ctx, owner, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := s.waitForDeletion(cl, dependents); err != nil {
+			errs = append(errs, fmt.Errorf("deleting %s did not cascade: %w", testutils.ResourceID(owner), err))
+		}
+	}
+
+	return errs
+}
+
+// ownerNotMatched returns the error for the owner referenced by ref matching owners, which are not exactly one.
+func ownerNotMatched(ref harness.ObjectReference, owners []unstructured.Unstructured) error {
+	if len(owners) == 0 {
+		return fmt.Errorf("owner %s %s not found", ref.Kind, describeReference(ref))
+	}
+	ids := make([]string, 0, len(owners))
+	for i := range owners {
+		ids = append(ids, testutils.ResourceID(&owners[i]))
+	}
+	sort.Strings(ids)
+	return fmt.Errorf("owner %s %s matched %d objects, it must match exactly one: %s", ref.Kind, describeReference(ref), len(owners), strings.Join(ids, ", "))
+}
+
+// checkOwnerReference checks the owner reference of dependent to owner against check.
+func checkOwnerReference(owner, dependent *unstructured.Unstructured, check harness.OwnershipCheck) error {
+	var ownerRef *metav1.OwnerReference
+	refs := dependent.GetOwnerReferences()
+	for i := range refs {
+		if refs[i].UID == owner.GetUID() {
+			ownerRef = &refs[i]
+			break
+		}
+	}
+
+	ownerID, dependentID := testutils.ResourceID(owner), testutils.ResourceID(dependent)
+	if ownerRef == nil {
+		for _, ref := range refs {
+			if ref.Kind == owner.GetKind() && ref.Name == owner.GetName() {
+				return fmt.Errorf("%s is not owned by %s: its owner reference has the uid %s instead of %s", dependentID, ownerID, ref.UID, owner.GetUID())
+			}
+		}
+		return fmt.Errorf("%s is not owned by %s", dependentID, ownerID)
+	}
+
+	if owner.GetNamespace() != "" && owner.GetNamespace() != dependent.GetNamespace() {
+		return fmt.Errorf("%s is owned by %s in another namespace, it is not garbage collected when its owner is deleted", dependentID, ownerID)
+	}
+	if check.Controller && (ownerRef.Controller == nil || !*ownerRef.Controller) {
+		return fmt.Errorf("%s is owned by %s, but it is not its controller", dependentID, ownerID)
+	}
+	if check.BlockOwnerDeletion && (ownerRef.BlockOwnerDeletion == nil || !*ownerRef.BlockOwnerDeletion) {
+		return fmt.Errorf("%s is owned by %s, but it does not block the deletion of its owner", dependentID, ownerID)
+	}
+	return nil
+}
+
+// describeReference describes ref by its name, or its labels when it has no name.
+func describeReference(ref harness.ObjectReference) string {
+	if ref.Name != "" {
+		return ref.Name
+	}
+	return fmt.Sprintf("labels %v", ref.Labels)
+}
+
+func validateOwnershipChecks(checks []harness.OwnershipCheck) error {
+	for i, check := range checks {
+		if check.Owner.APIVersion == "" || check.Owner.Kind == "" || (check.Owner.Name == "" && len(check.Owner.Labels) == 0) {
+			return fmt.Errorf("owner of ownership check %d must have an apiVersion, a kind and a name or labels", i)
+		}
+		if len(check.Dependents) == 0 {
+			return fmt.Errorf("ownership check %d of %s %s must have dependents", i, check.Owner.Kind, describeReference(check.Owner))
+		}
+		for j, ref := range check.Dependents {
+			if ref.APIVersion == "" || ref.Kind == "" {
+				return fmt.Errorf("dependent %d of ownership check %d must have an apiVersion and a kind", j, i)
+			}
+		}
+	}
+	return nil
+}
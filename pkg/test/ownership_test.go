@@ -0,0 +1,261 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+func TestCheckOwnership(t *testing.T) {
+	enabled := true
+
+	owner := testutils.NewV1Pod("owner", testNamespace, "default")
+	owner.UID = "owner"
+	owner.Labels = map[string]string{"role": "owner", "generation": "current"}
+	controlled := testutils.NewV1Pod("controlled", testNamespace, "default")
+	controlled.Labels = map[string]string{"app": "web"}
+	controlled.OwnerReferences = []metav1.OwnerReference{
+		{APIVersion: "v1", Kind: "Pod", Name: "owner", UID: "owner", Controller: &enabled, BlockOwnerDeletion: &enabled},
+	}
+	owned := testutils.NewV1Pod("owned", testNamespace, "default")
+	owned.Labels = map[string]string{"app": "web"}
+	owned.OwnerReferences = []metav1.OwnerReference{{APIVersion: "v1", Kind: "Pod", Name: "owner", UID: "owner"}}
+	stale := testutils.NewV1Pod("stale", testNamespace, "default")
+	stale.Labels = map[string]string{"role": "owner"}
+	stale.OwnerReferences = []metav1.OwnerReference{{APIVersion: "v1", Kind: "Pod", Name: "owner", UID: "previous"}}
+	orphan := testutils.NewV1Pod("orphan", testNamespace, "default")
+	elsewhere := testutils.NewV1Pod("elsewhere", "other", "default")
+	elsewhere.OwnerReferences = []metav1.OwnerReference{{APIVersion: "v1", Kind: "Pod", Name: "owner", UID: "owner"}}
+
+	cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(owner, controlled, owned, stale, orphan, elsewhere).Build()
+
+	step := Step{
+		Logger:          testutils.NewTestLogger(t, ""),
+		Client:          func(bool) (client.Client, error) { return cl, nil },
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+	}
+
+	for _, test := range []struct {
+		name         string
+		check        harness.OwnershipCheck
+		expectedErrs []string
+	}{
+		{
+			name: "controlled dependent",
+			check: harness.OwnershipCheck{
+				Owner:              podReference("owner", nil),
+				Dependents:         []harness.ObjectReference{podReference("controlled", nil)},
+				Controller:         true,
+				BlockOwnerDeletion: true,
+			},
+		},
+		{
+			name: "dependents selected by labels",
+			check: harness.OwnershipCheck{
+				Owner:      podReference("owner", nil),
+				Dependents: []harness.ObjectReference{podReference("", map[string]string{"app": "web"})},
+			},
+		},
+		{
+			name: "dependents not controlled",
+			check: harness.OwnershipCheck{
+				Owner:      podReference("owner", nil),
+				Dependents: []harness.ObjectReference{podReference("", map[string]string{"app": "web"})},
+				Controller: true,
+			},
+			expectedErrs: []string{"Pod:world/owned is owned by Pod:world/owner, but it is not its controller"},
+		},
+		{
+			name: "owner deletion not blocked",
+			check: harness.OwnershipCheck{
+				Owner:              podReference("owner", nil),
+				Dependents:         []harness.ObjectReference{podReference("owned", nil)},
+				BlockOwnerDeletion: true,
+			},
+			expectedErrs: []string{"Pod:world/owned is owned by Pod:world/owner, but it does not block the deletion of its owner"},
+		},
+		{
+			name: "missing and stale owner references",
+			check: harness.OwnershipCheck{
+				Owner:      podReference("owner", nil),
+				Dependents: []harness.ObjectReference{podReference("orphan", nil), podReference("stale", nil)},
+			},
+			expectedErrs: []string{
+				"Pod:world/orphan is not owned by Pod:world/owner",
+				"Pod:world/stale is not owned by Pod:world/owner: its owner reference has the uid previous instead of owner",
+			},
+		},
+		{
+			name: "dependent in another namespace",
+			check: harness.OwnershipCheck{
+				Owner:      podReference("owner", nil),
+				Dependents: []harness.ObjectReference{namespacedPodReference("elsewhere", "other")},
+			},
+			expectedErrs: []string{"Pod:other/elsewhere is owned by Pod:world/owner in another namespace, it is not garbage collected when its owner is deleted"},
+		},
+		{
+			name: "no dependents matched",
+			check: harness.OwnershipCheck{
+				Owner:      podReference("owner", nil),
+				Dependents: []harness.ObjectReference{podReference("", map[string]string{"app": "cache"})},
+			},
+			expectedErrs: []string{"no Pod dependents of Pod:world/owner matched labels map[app:cache]"},
+		},
+		{
+			name: "owner selected by labels",
+			check: harness.OwnershipCheck{
+				Owner:      podReference("", map[string]string{"generation": "current"}),
+				Dependents: []harness.ObjectReference{podReference("owned", nil)},
+			},
+		},
+		{
+			name: "several owners selected by labels",
+			check: harness.OwnershipCheck{
+				Owner:      podReference("", map[string]string{"role": "owner"}),
+				Dependents: []harness.ObjectReference{podReference("owned", nil)},
+			},
+			expectedErrs: []string{"owner Pod labels map[role:owner] matched 2 objects, it must match exactly one: Pod:world/owner, Pod:world/stale"},
+		},
+		{
+			name: "owner not found",
+			check: harness.OwnershipCheck{
+				Owner:      podReference("deleted", nil),
+				Dependents: []harness.ObjectReference{podReference("owned", nil)},
+			},
+			expectedErrs: []string{"owner Pod deleted not found"},
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			errs := []string{}
+			for _, err := range step.CheckOwnership(testNamespace, []harness.OwnershipCheck{test.check}) {
+				errs = append(errs, err.Error())
+			}
+			if len(test.expectedErrs) == 0 {
+				assert.Empty(t, errs)
+			} else {
+				assert.ElementsMatch(t, test.expectedErrs, errs)
+			}
+		})
+	}
+}
+
+func TestValidateOwnershipChecks(t *testing.T) {
+	assert.NoError(t, validateOwnershipChecks([]harness.OwnershipCheck{
+		{Owner: podReference("owner", nil), Dependents: []harness.ObjectReference{podReference("", map[string]string{"app": "web"})}},
+		{Owner: podReference("", map[string]string{"app": "web"}), Dependents: []harness.ObjectReference{podReference("owned", nil)}},
+	}))
+
+	assert.EqualError(t, validateOwnershipChecks([]harness.OwnershipCheck{
+		{Owner: podReference("", nil), Dependents: []harness.ObjectReference{podReference("owned", nil)}},
+	}), "owner of ownership check 0 must have an apiVersion, a kind and a name or labels")
+
+	assert.EqualError(t, validateOwnershipChecks([]harness.OwnershipCheck{
+		{Owner: podReference("owner", nil)},
+	}), "ownership check 0 of Pod owner must have dependents")
+
+	dependent := podReference("owned", nil)
+	dependent.Kind = ""
+	assert.EqualError(t, validateOwnershipChecks([]harness.OwnershipCheck{
+		{Owner: podReference("owner", nil), Dependents: []harness.ObjectReference{podReference("owned", nil), dependent}},
+	}), "dependent 1 of ownership check 0 must have an apiVersion and a kind")
+}
+
+func TestCascadeOwnership(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		gc           bool
+		expectedErrs []string
+	}{
+		{
+			name: "dependents garbage collected",
+			gc:   true,
+		},
+		{
+			name:         "dependents not garbage collected",
+			expectedErrs: []string{"deleting Pod:world/owner did not cascade: objects were not deleted: Pod:world/owned is not being deleted, owned by Pod owner"},
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			owner := testutils.NewV1Pod("owner", testNamespace, "default")
+			owner.UID = "owner"
+			owned := testutils.NewV1Pod("owned", testNamespace, "default")
+			owned.OwnerReferences = []metav1.OwnerReference{{APIVersion: "v1", Kind: "Pod", Name: "owner", UID: "owner"}}
+
+			var cl client.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(owner, owned).Build()
+			if test.gc {
+				cl = &gcClient{Client: cl}
+			}
+
+			step := Step{
+				Logger:          testutils.NewTestLogger(t, ""),
+				Timeout:         1,
+				Client:          func(bool) (client.Client, error) { return cl, nil },
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+			}
+
+			errs := []string{}
+			for _, err := range step.CascadeOwnership(testNamespace, []harness.OwnershipCheck{
+				{Owner: podReference("not-cascading", nil), Dependents: []harness.ObjectReference{podReference("owned", nil)}},
+				{Owner: podReference("owner", nil), Dependents: []harness.ObjectReference{podReference("owned", nil)}, Cascade: true},
+			}) {
+				errs = append(errs, err.Error())
+			}
+			if len(test.expectedErrs) == 0 {
+				assert.Empty(t, errs)
+			} else {
+				assert.Equal(t, test.expectedErrs, errs)
+			}
+
+			// the owner is deleted in any case
+			err := cl.Get(context.TODO(), client.ObjectKeyFromObject(owner), &corev1.Pod{})
+			assert.True(t, k8serrors.IsNotFound(err))
+		})
+	}
+}
+
+// gcClient emulates the garbage collector: deleting a pod deletes the pods it owns.
+type gcClient struct {
+	client.Client
+}
+
+func (c *gcClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.Client.Delete(ctx, obj, opts...); err != nil {
+		return err
+	}
+
+	pods := &corev1.PodList{}
+	if err := c.Client.List(ctx, pods, client.InNamespace(obj.GetNamespace())); err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		for _, ref := range pods.Items[i].OwnerReferences {
+			if ref.UID == obj.GetUID() {
+				if err := c.Client.Delete(ctx, &pods.Items[i]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func namespacedPodReference(name, namespace string) harness.ObjectReference {
+	ref := podReference(name, nil)
+	ref.Namespace = namespace
+	return ref
+}
@@ -0,0 +1,220 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	"github.com/kyverno/kuttl/pkg/env"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+// patchTypes maps the patch types of a TestStep to the patch types of the API server.
+var patchTypes = map[harness.PatchType]types.PatchType{
+	"":                     types.StrategicMergePatchType,
+	harness.PatchJSON:      types.JSONPatchType,
+	harness.PatchMerge:     types.MergePatchType,
+	harness.PatchStrategic: types.StrategicMergePatchType,
+}
+
+type patch struct {
+	ref       harness.ObjectReference
+	patchType types.PatchType
+	// body is the decoded body of the patch, so that variables can be expanded in its string values.
+	body          interface{}
+	shouldFail    bool
+	expectedError *harness.ExpectedError
+}
+
+// loadPatch loads the body of p from the TestStep or from its file, relative to dir.
+func loadPatch(p harness.Patch, dir string) (patch, error) {
+	patchType, ok := patchTypes[p.Type]
+	if !ok {
+		return patch{}, fmt.Errorf("unsupported patch type %q", p.Type)
+	}
+
+	data := []byte(p.Patch)
+	if p.File != "" {
+		var err error
+		if data, err = os.ReadFile(cleanPath(env.Expand(p.File), dir)); err != nil {
+			return patch{}, err
+		}
+	}
+
+	var body interface{}
+	if err := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), len(data)).Decode(&body); err != nil {
+		return patch{}, fmt.Errorf("decoding patch of %s: %w", p.Object.Name, err)
+	}
+
+	return patch{ref: p.Object, patchType: patchType, body: body, shouldFail: p.ShouldFail, expectedError: p.ExpectedError}, nil
+}
+
+// Patch patches all objects referenced in the Patches list.
+func (s *Step) Patch(namespace string) []error {
+	cl, err := s.Client(false)
+	if err != nil {
+		return []error{err}
+	}
+
+	dClient, err := s.DiscoveryClient()
+	if err != nil {
+		return []error{err}
+	}
+
+	errs := []error{}
+
+	for _, p := range s.Patches {
+		err := doPatch(s.Logger, s.Timeout, dClient, cl, p, namespace)
+		shouldFail := p.shouldFail || p.expectedError != nil
+		if err != nil && !shouldFail {
+			errs = append(errs, err)
+		}
+		if err != nil && p.expectedError != nil {
+			if err := p.expectedError.ValidateError(err); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err == nil && shouldFail {
+			errs = append(errs, fmt.Errorf("an error was expected when patching %s but didn't happen", p.ref.Name))
+		}
+	}
+
+	return errs
+}
+
+func doPatch(logger testutils.Logger, timeout int, dClient discovery.DiscoveryInterface, cl client.Client, p patch, namespace string) error {
+	gvk := p.ref.GroupVersionKind()
+
+	obj := testutils.NewResource(gvk.GroupVersion().String(), gvk.Kind, p.ref.Name, p.ref.Namespace)
+	if _, _, err := testutils.Namespaced(dClient, obj, namespace); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(p.body)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
+
+	if err := testutils.WithMappingRefresh(cl, func() error {
+		return cl.Patch(ctx, obj, client.RawPatch(p.patchType, data))
+	}); err != nil {
+		return fmt.Errorf("patching %s: %w", testutils.ResourceID(obj), err)
+	}
+	logger.Log(testutils.ResourceID(obj), "patched")
+	return nil
+}
+
+// Replace replaces the existing objects with the objects in the Replaces list.
+func (s *Step) Replace(namespace string) []error {
+	cl, err := s.Client(false)
+	if err != nil {
+		return []error{err}
+	}
+
+	dClient, err := s.DiscoveryClient()
+	if err != nil {
+		return []error{err}
+	}
+
+	errs := []error{}
+
+	for _, replace := range s.Replaces {
+		err := doReplace(s.Logger, s.Timeout, dClient, cl, replace.object, namespace)
+		shouldFail := replace.shouldFail || replace.expectedError != nil
+		if err != nil && !shouldFail {
+			errs = append(errs, err)
+		}
+		if err != nil && replace.expectedError != nil {
+			if err := replace.expectedError.ValidateError(err); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err == nil && shouldFail {
+			errs = append(errs, fmt.Errorf("an error was expected when replacing %s but didn't happen", testutils.ResourceID(replace.object)))
+		}
+	}
+
+	return errs
+}
+
+// doReplace updates the existing object with obj, it fails if the object does not exist. The resource version of the
+// existing object is used unless obj sets one, conflicts with concurrent updates are retried.
+func doReplace(logger testutils.Logger, timeout int, dClient discovery.DiscoveryInterface, cl client.Client, obj client.Object, namespace string) error {
+	if _, _, err := testutils.Namespaced(dClient, obj, namespace); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
+
+	resourceVersion := obj.GetResourceVersion()
+
+	err := testutils.Retry(ctx, func(ctx context.Context) error {
+		return testutils.WithMappingRefresh(cl, func() error {
+			if resourceVersion == "" {
+				actual := &unstructured.Unstructured{}
+				actual.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+				if err := cl.Get(ctx, testutils.ObjectKey(obj), actual); err != nil {
+					return err
+				}
+				obj.SetResourceVersion(actual.GetResourceVersion())
+			}
+			return cl.Update(ctx, obj)
+		})
+	}, func(err error) bool {
+		// only retry conflicts caused by the resource version read from the existing object
+		return resourceVersion == "" && k8serrors.IsConflict(err)
+	})
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = errors.New("replace timeout exceeded")
+	}
+	if err != nil {
+		return fmt.Errorf("replacing %s: %w", testutils.ResourceID(obj), err)
+	}
+	logger.Log(testutils.ResourceID(obj), "replaced")
+	return nil
+}
+
+func validatePatches(patches []harness.Patch, baseDir string) error {
+	for _, p := range patches {
+		if p.Object.Kind == "" || p.Object.APIVersion == "" || p.Object.Name == "" {
+			return fmt.Errorf("object of patch must have an apiVersion, kind and name")
+		}
+		if _, ok := patchTypes[p.Type]; !ok {
+			return fmt.Errorf("unsupported type %q for patch of %s", p.Type, p.Object.Name)
+		}
+		if (p.Patch == "") == (p.File == "") {
+			return fmt.Errorf("patch of %s must set exactly one of patch and file", p.Object.Name)
+		}
+		if p.File != "" {
+			path := cleanPath(p.File, baseDir)
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				return fmt.Errorf("referenced file in Patch does not exist: %s", path)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+func TestStepPatch(t *testing.T) {
+	podRef := func(name string) harness.ObjectReference {
+		return harness.ObjectReference{ObjectReference: corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Name: name}}
+	}
+
+	for _, test := range []struct {
+		name               string
+		patch              harness.Patch
+		expectedLabels     map[string]string
+		expectedFinalizers []string
+		expectedErr        string
+	}{
+		{
+			name:               "strategic merge patch",
+			patch:              harness.Patch{Object: podRef("hello"), Patch: "metadata:\n  labels:\n    tier: frontend\n"},
+			expectedLabels:     map[string]string{"app": "hello", "tier": "frontend"},
+			expectedFinalizers: []string{"example.com/protect", "example.com/cleanup"},
+		},
+		{
+			name:               "merge patch removing a label",
+			patch:              harness.Patch{Object: podRef("hello"), Type: harness.PatchMerge, Patch: `{"metadata":{"labels":{"app":null}}}`},
+			expectedFinalizers: []string{"example.com/protect", "example.com/cleanup"},
+		},
+		{
+			name:               "json patch removing a finalizer",
+			patch:              harness.Patch{Object: podRef("hello"), Type: harness.PatchJSON, Patch: `[{"op":"remove","path":"/metadata/finalizers/0"}]`},
+			expectedLabels:     map[string]string{"app": "hello"},
+			expectedFinalizers: []string{"example.com/cleanup"},
+		},
+		{
+			name:               "expected failure",
+			patch:              harness.Patch{Object: podRef("hello"), Type: harness.PatchJSON, Patch: `[{"op":"test","path":"/metadata/labels/app","value":"other"}]`, ShouldFail: true},
+			expectedLabels:     map[string]string{"app": "hello"},
+			expectedFinalizers: []string{"example.com/protect", "example.com/cleanup"},
+		},
+		{
+			name:               "expected failure did not happen",
+			patch:              harness.Patch{Object: podRef("hello"), Type: harness.PatchMerge, Patch: `{}`, ShouldFail: true},
+			expectedLabels:     map[string]string{"app": "hello"},
+			expectedFinalizers: []string{"example.com/protect", "example.com/cleanup"},
+			expectedErr:        "an error was expected when patching hello but didn't happen",
+		},
+		{
+			name:               "object does not exist",
+			patch:              harness.Patch{Object: podRef("missing"), Patch: `{}`},
+			expectedLabels:     map[string]string{"app": "hello"},
+			expectedFinalizers: []string{"example.com/protect", "example.com/cleanup"},
+			expectedErr:        `patching Pod:world/missing: pods "missing" not found`,
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			pod := testutils.WithLabels(t, testutils.NewPod("hello", testNamespace), map[string]string{"app": "hello"})
+			pod.SetFinalizers([]string{"example.com/protect", "example.com/cleanup"})
+
+			cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(pod).Build()
+
+			p, err := loadPatch(test.patch, "")
+			assert.NoError(t, err)
+
+			step := Step{
+				Logger:          testutils.NewTestLogger(t, ""),
+				Patches:         []patch{p},
+				Client:          func(bool) (client.Client, error) { return cl, nil },
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+			}
+
+			errs := step.Patch(testNamespace)
+			if test.expectedErr != "" {
+				assert.Len(t, errs, 1)
+				assert.EqualError(t, errs[0], test.expectedErr)
+			} else {
+				assert.Equal(t, []error{}, errs)
+			}
+
+			actual := &corev1.Pod{}
+			assert.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: "hello"}, actual))
+			assert.Equal(t, test.expectedLabels, actual.Labels)
+			assert.Equal(t, test.expectedFinalizers, actual.Finalizers)
+		})
+	}
+}
+
+func TestStepReplace(t *testing.T) {
+	existing := testutils.WithLabels(t, testutils.NewPod("hello", testNamespace), map[string]string{"app": "hello"})
+	cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(existing).Build()
+
+	step := Step{
+		Logger: testutils.NewTestLogger(t, ""),
+		Replaces: []apply{
+			{object: testutils.WithLabels(t, testutils.NewPod("hello", ""), map[string]string{"tier": "frontend"})},
+			{object: testutils.NewPod("missing", ""), shouldFail: true},
+		},
+		Client:          func(bool) (client.Client, error) { return cl, nil },
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+	}
+
+	assert.Equal(t, []error{}, step.Replace(testNamespace))
+
+	actual := &corev1.Pod{}
+	assert.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: "hello"}, actual))
+	assert.Equal(t, map[string]string{"tier": "frontend"}, actual.Labels)
+	assert.True(t, k8serrors.IsNotFound(cl.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: "missing"}, actual)))
+
+	step.Replaces = []apply{{object: testutils.NewPod("missing", "")}}
+	errs := step.Replace(testNamespace)
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], `replacing Pod:world/missing: pods "missing" not found`)
+}
+
+func TestLoadPatch(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "patch.yaml"), []byte("- op: remove\n  path: /metadata/finalizers/0\n"), 0600))
+
+	object := harness.ObjectReference{ObjectReference: corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Name: "hello"}}
+
+	p, err := loadPatch(harness.Patch{Object: object, Type: harness.PatchJSON, File: "patch.yaml"}, dir)
+	assert.NoError(t, err)
+	assert.Equal(t, types.JSONPatchType, p.patchType)
+	assert.Equal(t, []interface{}{map[string]interface{}{"op": "remove", "path": "/metadata/finalizers/0"}}, p.body)
+
+	p, err = loadPatch(harness.Patch{Object: object, Patch: "spec:\n  restartPolicy: Never\n"}, dir)
+	assert.NoError(t, err)
+	assert.Equal(t, types.StrategicMergePatchType, p.patchType)
+	assert.Equal(t, map[string]interface{}{"spec": map[string]interface{}{"restartPolicy": "Never"}}, p.body)
+
+	_, err = loadPatch(harness.Patch{Object: object, Type: "Apply", Patch: "{}"}, dir)
+	assert.EqualError(t, err, `unsupported patch type "Apply"`)
+}
+
+func TestValidatePatches(t *testing.T) {
+	object := harness.ObjectReference{ObjectReference: corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Name: "hello"}}
+
+	assert.NoError(t, validatePatches([]harness.Patch{{Object: object, Patch: "{}"}}, ""))
+	assert.EqualError(t, validatePatches([]harness.Patch{{Object: harness.ObjectReference{}, Patch: "{}"}}, ""), "object of patch must have an apiVersion, kind and name")
+	assert.EqualError(t, validatePatches([]harness.Patch{{Object: object, Type: "Apply", Patch: "{}"}}, ""), `unsupported type "Apply" for patch of hello`)
+	assert.EqualError(t, validatePatches([]harness.Patch{{Object: object}}, ""), "patch of hello must set exactly one of patch and file")
+	assert.EqualError(t, validatePatches([]harness.Patch{{Object: object, Patch: "{}", File: "patch.yaml"}}, ""), "patch of hello must set exactly one of patch and file")
+	assert.EqualError(t, validatePatches([]harness.Patch{{Object: object, File: "patch.yaml"}}, "dir"), "referenced file in Patch does not exist: dir/patch.yaml")
+}
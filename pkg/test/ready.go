@@ -0,0 +1,80 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+// readyPollInterval is the interval at which the readiness of the applied objects is checked.
+var readyPollInterval = time.Second
+
+// WaitForReady waits until the applied objects of the step which must be ready are ready, until the timeout of the
+// step expires or until ctx is done. It returns an error for each object which is not ready.
+func (s *Step) WaitForReady(ctx context.Context, namespace string) []error {
+	pending := []client.Object{}
+	for _, apply := range s.Apply {
+		if apply.shouldFail || apply.expectedError != nil || !s.waitForReady(apply) {
+			continue
+		}
+		pending = append(pending, apply.object)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	cl, err := s.Client(false)
+	if err != nil {
+		return []error{err}
+	}
+
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(s.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	var errs []error
+	err = wait.PollImmediateUntilWithContext(ctx, readyPollInterval, func(ctx context.Context) (bool, error) {
+		errs = []error{}
+		notReady := []client.Object{}
+
+		for _, obj := range pending {
+			actual := &unstructured.Unstructured{}
+			actual.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+			if err := cl.Get(ctx, testutils.ObjectKey(obj), actual); err != nil {
+				errs = append(errs, fmt.Errorf("%s is not ready: %w", testutils.ResourceID(obj), err))
+				notReady = append(notReady, obj)
+				continue
+			}
+
+			if ready, reason := testutils.Readiness(actual); !ready {
+				errs = append(errs, fmt.Errorf("%s is not ready: %s", testutils.ResourceID(obj), reason))
+				notReady = append(notReady, obj)
+				continue
+			}
+			s.Logger.Log(testutils.ResourceID(obj), "ready")
+		}
+
+		pending = notReady
+		return len(pending) == 0, nil
+	})
+	if err != nil && len(errs) == 0 {
+		return []error{err}
+	}
+	return errs
+}
+
+// waitForReady returns whether the step must wait for the object of apply to be ready.
+func (s *Step) waitForReady(apply apply) bool {
+	if apply.waitForReady != nil {
+		return *apply.waitForReady
+	}
+	return s.Step != nil && s.Step.WaitForReady
+}
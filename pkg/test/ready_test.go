@@ -0,0 +1,59 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+func TestStepWaitForReady(t *testing.T) {
+	readyPollInterval = 10 * time.Millisecond
+
+	ready := testutils.NewV1Pod("ready", testNamespace, "default")
+	ready.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+	pending := testutils.NewV1Pod("pending", testNamespace, "default")
+	pending.Status.Phase = corev1.PodPending
+
+	cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(ready, pending).Build()
+
+	newStep := func(step *harness.TestStep, applies ...apply) *Step {
+		return &Step{
+			Step:            step,
+			Apply:           applies,
+			Timeout:         1,
+			Logger:          testutils.NewTestLogger(t, ""),
+			Client:          func(bool) (client.Client, error) { return cl, nil },
+			DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+		}
+	}
+	enabled, disabled := true, false
+
+	step := newStep(&harness.TestStep{WaitForReady: true},
+		apply{object: testutils.NewPod("ready", testNamespace)},
+		apply{object: testutils.NewPod("pending", testNamespace), waitForReady: &disabled},
+		apply{object: testutils.NewPod("rejected", testNamespace), shouldFail: true},
+	)
+	assert.Empty(t, step.WaitForReady(context.TODO(), testNamespace))
+
+	step = newStep(nil,
+		apply{object: testutils.NewPod("ready", testNamespace), waitForReady: &enabled},
+		apply{object: testutils.NewPod("pending", testNamespace), waitForReady: &enabled},
+		apply{object: testutils.NewPod("missing", testNamespace), waitForReady: &enabled},
+	)
+	errs := step.WaitForReady(context.TODO(), testNamespace)
+	assert.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], "Pod:world/pending is not ready: the Ready condition is not set")
+	assert.EqualError(t, errs[1], `Pod:world/missing is not ready: pods "missing" not found`)
+
+	assert.Empty(t, newStep(nil, apply{object: testutils.NewPod("pending", testNamespace)}).WaitForReady(context.TODO(), testNamespace))
+}
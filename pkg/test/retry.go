@@ -0,0 +1,121 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+// retryPolicy is a retry policy with its message patterns compiled.
+type retryPolicy struct {
+	*harness.RetryPolicy
+	messages []*regexp.Regexp
+}
+
+// compileRetryPolicy validates policy and compiles its message patterns, it returns nil if policy is nil.
+func compileRetryPolicy(policy *harness.RetryPolicy) (*retryPolicy, error) {
+	if policy == nil {
+		return nil, nil
+	}
+	if err := validateRetryPolicy(policy); err != nil {
+		return nil, err
+	}
+
+	compiled := &retryPolicy{RetryPolicy: policy}
+	for _, message := range policy.Messages {
+		pattern, err := regexp.Compile(message)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message pattern %q: %w", message, err)
+		}
+		compiled.messages = append(compiled.messages, pattern)
+	}
+	return compiled, nil
+}
+
+// retryReason returns why err should be retried according to the error classes and the message patterns of the
+// policy, or an empty string if it should not be.
+func (p *retryPolicy) retryReason(err error) string {
+	if reason := p.RetryReason(err); reason != "" || err == nil {
+		return reason
+	}
+	for _, pattern := range p.messages {
+		if pattern.MatchString(err.Error()) {
+			return fmt.Sprintf("message matches %q", pattern.String())
+		}
+	}
+	return ""
+}
+
+// retrying calls fn until it succeeds or fails with an error policy does not retry, waiting the backoff of policy
+// between the attempts. Each retry is logged with its reason, description identifies the request. The retries stop
+// once ctx expires or the maximum duration of policy elapsed, the last error is returned then.
+func retrying(ctx context.Context, policy *retryPolicy, logger testutils.Logger, description string, fn func(ctx context.Context) error) error {
+	if policy == nil {
+		return fn(ctx)
+	}
+
+	if policy.MaxDuration != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.MaxDuration.Duration)
+		defer cancel()
+	}
+
+	for retries := 0; ; retries++ {
+		err := fn(ctx)
+		reason := policy.retryReason(err)
+		if reason == "" {
+			return err
+		}
+
+		backoff := policy.RetryBackoff(retries)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+			return err
+		}
+		logger.Logf("%s: retrying in %s (%s): %v", description, backoff, reason, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// retrying calls fn with the retry policy of the step, until the timeout of the step expires.
+func (s *Step) retrying(description string, fn func(ctx context.Context) error) error {
+	ctx := context.Background()
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(s.Timeout)*time.Second)
+		defer cancel()
+	}
+	return retrying(ctx, s.Retry, s.Logger, description, fn)
+}
+
+func validateRetryPolicy(policy *harness.RetryPolicy) error {
+	for _, class := range policy.Errors {
+		switch class {
+		case harness.RetryWebhookUnavailable, harness.RetryServerUnavailable, harness.RetryConflict:
+		default:
+			return fmt.Errorf("unknown error class %q, expected one of %s, %s or %s", class,
+				harness.RetryWebhookUnavailable, harness.RetryServerUnavailable, harness.RetryConflict)
+		}
+	}
+	if len(policy.Errors) == 0 && len(policy.Messages) == 0 {
+		return errors.New("at least one of errors and messages must be set")
+	}
+	if policy.Backoff != nil && policy.Backoff.Duration <= 0 {
+		return errors.New("backoff must be positive")
+	}
+	if policy.MaxDuration != nil && policy.MaxDuration.Duration <= 0 {
+		return errors.New("maxDuration must be positive")
+	}
+	return nil
+}
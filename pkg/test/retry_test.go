@@ -0,0 +1,163 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+func TestStepCreateRetry(t *testing.T) {
+	policy, err := compileRetryPolicy(&harness.RetryPolicy{
+		Errors:  []harness.RetryableError{harness.RetryWebhookUnavailable},
+		Backoff: &metav1.Duration{Duration: 10 * time.Millisecond},
+	})
+	assert.NoError(t, err)
+
+	for _, test := range []struct {
+		name        string
+		retry       *retryPolicy
+		expectedErr string
+		attempts    int
+	}{
+		{
+			name:     "retried until the webhook is available",
+			retry:    policy,
+			attempts: 3,
+		},
+		{
+			name:        "not retried without a policy",
+			expectedErr: `Internal error occurred: failed calling webhook "mutate.kyverno.svc-fail": no endpoints available for service "kyverno-svc"`,
+			attempts:    1,
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			cl := &webhookClient{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), unavailable: 2}
+
+			step := Step{
+				Logger:          testutils.NewTestLogger(t, ""),
+				Timeout:         5,
+				Apply:           []apply{{object: testutils.NewPod("hello", testNamespace)}},
+				Client:          func(bool) (client.Client, error) { return cl, nil },
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+				SkipDelete:      true,
+				Retry:           test.retry,
+			}
+
+			errs := step.Create(t, testNamespace)
+			if test.expectedErr != "" {
+				assert.Len(t, errs, 1)
+				assert.EqualError(t, errs[0], test.expectedErr)
+			} else {
+				assert.Equal(t, []error{}, errs)
+			}
+			assert.Equal(t, test.attempts, cl.attempts)
+		})
+	}
+}
+
+func TestRetryingMaxDuration(t *testing.T) {
+	attempts := 0
+	start := time.Now()
+	policy, err := compileRetryPolicy(&harness.RetryPolicy{
+		Messages:    []string{"connection refused"},
+		Backoff:     &metav1.Duration{Duration: 10 * time.Millisecond},
+		MaxDuration: &metav1.Duration{Duration: 100 * time.Millisecond},
+	})
+	assert.NoError(t, err)
+
+	err = retrying(context.Background(), policy, testutils.NewTestLogger(t, ""), "Pod:world/hello", func(ctx context.Context) error {
+		attempts++
+		return errors.New("dial tcp 127.0.0.1:6443: connect: connection refused")
+	})
+
+	assert.EqualError(t, err, "dial tcp 127.0.0.1:6443: connect: connection refused")
+	assert.Greater(t, attempts, 1)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestRetryReasonMessages(t *testing.T) {
+	policy, err := compileRetryPolicy(&harness.RetryPolicy{
+		Errors:   []harness.RetryableError{harness.RetryConflict},
+		Messages: []string{"policy .* is not ready"},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "", policy.retryReason(nil))
+	assert.Equal(t, `message matches "policy .* is not ready"`, policy.retryReason(errors.New(`admission webhook "validate.kyverno.svc" denied the request: policy require-labels is not ready`)))
+	assert.Equal(t, "", policy.retryReason(errors.New("policy require-labels denied the request")))
+	assert.Equal(t, "Conflict", policy.retryReason(k8serrors.NewConflict(schema.GroupResource{Resource: "pods"}, "hello", errors.New("the object has been modified"))))
+}
+
+func TestCompileRetryPolicy(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		policy      harness.RetryPolicy
+		expectedErr string
+	}{
+		{
+			name:   "valid policy",
+			policy: harness.RetryPolicy{Errors: []harness.RetryableError{harness.RetryWebhookUnavailable}, Messages: []string{"not ready"}},
+		},
+		{
+			name:        "unknown error class",
+			policy:      harness.RetryPolicy{Errors: []harness.RetryableError{"Timeout"}},
+			expectedErr: `unknown error class "Timeout", expected one of WebhookUnavailable, ServerUnavailable or Conflict`,
+		},
+		{
+			name:        "invalid message",
+			policy:      harness.RetryPolicy{Messages: []string{"not ready ("}},
+			expectedErr: "invalid message pattern \"not ready (\": error parsing regexp: missing closing ): `not ready (`",
+		},
+		{
+			name:        "nothing to retry",
+			policy:      harness.RetryPolicy{Backoff: &metav1.Duration{Duration: time.Second}},
+			expectedErr: "at least one of errors and messages must be set",
+		},
+		{
+			name:        "negative backoff",
+			policy:      harness.RetryPolicy{Errors: []harness.RetryableError{harness.RetryConflict}, Backoff: &metav1.Duration{Duration: -time.Second}},
+			expectedErr: "backoff must be positive",
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			_, err := compileRetryPolicy(&test.policy)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.expectedErr)
+			}
+		})
+	}
+}
+
+// webhookClient emulates an admission webhook which is unavailable for the first attempts to create objects.
+type webhookClient struct {
+	client.Client
+	unavailable int
+	attempts    int
+}
+
+func (c *webhookClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.attempts++
+	if c.attempts <= c.unavailable {
+		return k8serrors.NewInternalError(errors.New(`failed calling webhook "mutate.kyverno.svc-fail": no endpoints available for service "kyverno-svc"`))
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
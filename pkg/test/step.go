@@ -1,9 +1,11 @@
 package test
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -13,9 +15,13 @@ import (
 
 	wildcard "github.com/IGLOU-EU/go-wildcard"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/discovery"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -24,6 +30,7 @@ import (
 	"github.com/kyverno/kuttl/pkg/env"
 	kfile "github.com/kyverno/kuttl/pkg/file"
 	"github.com/kyverno/kuttl/pkg/http"
+	"github.com/kyverno/kuttl/pkg/report"
 	testutils "github.com/kyverno/kuttl/pkg/test/utils"
 )
 
@@ -34,6 +41,14 @@ var fileNameRegex = regexp.MustCompile(`^(?:\d+-)?([^-\.]+)(-[^\.]+)?(?:\.yaml)?
 type apply struct {
 	object     client.Object
 	shouldFail bool
+	// serverSideApply overrides the server-side apply configuration of the step for the object.
+	serverSideApply *harness.ServerSideApply
+	// expectedError defines the criteria the error should meet, it implies shouldFail.
+	expectedError *harness.ExpectedError
+	// warnings defines the criteria the warnings returned for the object should meet.
+	warnings *harness.Warnings
+	// waitForReady overrides the readiness wait of the step for the object.
+	waitForReady *bool
 }
 
 type asserts struct {
@@ -53,15 +68,36 @@ type Step struct {
 	Step   *harness.TestStep
 	Assert *harness.TestAssert
 
-	Asserts []asserts
-	Apply   []apply
-	Errors  []client.Object
+	Asserts  []asserts
+	Apply    []apply
+	Errors   []asserts
+	Patches  []patch
+	Replaces []apply
+	DryRuns  []dryRun
 
 	Timeout int
 
-	Kubeconfig      string
+	Kubeconfig string
+	// Impersonate is the subject the step acts as.
+	Impersonate *harness.Impersonation
+
 	Client          func(forceNew bool) (client.Client, error)
 	DiscoveryClient func() (discovery.DiscoveryInterface, error)
+	// HarnessClient is the client of the step without impersonation, it reviews the access of other subjects than
+	// the subject of the step. Client is used if it is not set.
+	HarnessClient func(forceNew bool) (client.Client, error)
+
+	// Variables of the test suite and captured by the steps of the test case, shared by all steps of the test case.
+	Variables map[string]string
+	// Templating configures the expansion of variables in the objects of the step.
+	Templating *harness.Templating
+	// ServerSideApply configures the server-side apply of the objects of the step.
+	ServerSideApply *harness.ServerSideApply
+	// Retry is the policy retrying the applies and deletions of the step failing with transient errors.
+	Retry *retryPolicy
+
+	// Warnings are the warnings returned by the API server while applying the objects of the step.
+	Warnings []report.Warning
 
 	Logger testutils.Logger
 }
@@ -84,7 +120,9 @@ func (s *Step) Clean(namespace string) error {
 			return err
 		}
 
-		if err := cl.Delete(context.TODO(), apply.object); err != nil && !k8serrors.IsNotFound(err) {
+		if err := s.retrying(testutils.ResourceID(apply.object), func(ctx context.Context) error {
+			return cl.Delete(ctx, apply.object)
+		}); err != nil && !k8serrors.IsNotFound(err) {
 			return err
 		}
 	}
@@ -92,98 +130,11 @@ func (s *Step) Clean(namespace string) error {
 	return nil
 }
 
-// DeleteExisting deletes any resources in the TestStep.Delete list prior to running the tests.
-func (s *Step) DeleteExisting(namespace string) error {
-	cl, err := s.Client(false)
-	if err != nil {
-		return err
-	}
-
-	dClient, err := s.DiscoveryClient()
-	if err != nil {
-		return err
-	}
-
-	toDelete := []client.Object{}
-
-	if s.Step == nil {
-		return nil
-	}
-
-	for _, ref := range s.Step.Delete {
-		gvk := ref.GroupVersionKind()
-
-		obj := testutils.NewResource(gvk.GroupVersion().String(), gvk.Kind, ref.Name, "")
-
-		objNs := namespace
-		if ref.Namespace != "" {
-			objNs = ref.Namespace
-		}
-
-		_, objNs, err := testutils.Namespaced(dClient, obj, objNs)
-		if err != nil {
-			return err
-		}
-
-		if ref.Name == "" {
-			u := &unstructured.UnstructuredList{}
-			u.SetGroupVersionKind(gvk)
-
-			listOptions := []client.ListOption{}
-
-			if ref.Labels != nil {
-				listOptions = append(listOptions, client.MatchingLabels(ref.Labels))
-			}
-
-			if objNs != "" {
-				listOptions = append(listOptions, client.InNamespace(objNs))
-			}
-
-			err := cl.List(context.TODO(), u, listOptions...)
-			if err != nil {
-				return fmt.Errorf("listing matching resources: %w", err)
-			}
-
-			for index := range u.Items {
-				toDelete = append(toDelete, &u.Items[index])
-			}
-		} else {
-			// Otherwise just append the object specified.
-			toDelete = append(toDelete, obj.DeepCopy())
-		}
-	}
-
-	for _, obj := range toDelete {
-		delete := &unstructured.Unstructured{}
-		delete.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
-		delete.SetName(obj.GetName())
-		delete.SetNamespace(obj.GetNamespace())
-
-		err := cl.Delete(context.TODO(), delete)
-		if err != nil && !k8serrors.IsNotFound(err) {
-			return err
-		}
-	}
-
-	// Wait for resources to be deleted.
-	return wait.PollImmediate(100*time.Millisecond, time.Duration(s.GetTimeout())*time.Second, func() (done bool, err error) {
-		for _, obj := range toDelete {
-			actual := &unstructured.Unstructured{}
-			actual.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
-			err = cl.Get(context.TODO(), testutils.ObjectKey(obj), actual)
-			if err == nil || !k8serrors.IsNotFound(err) {
-				return false, err
-			}
-		}
-
-		return true, nil
-	})
-}
-
-func doApply(test *testing.T, skipDelete bool, logger testutils.Logger, timeout int, dClient discovery.DiscoveryInterface, cl client.Client, obj client.Object, namespace string) error {
+// doApply creates or updates obj and returns the warnings returned by the API server.
+func doApply(test *testing.T, skipDelete bool, logger testutils.Logger, timeout int, dClient discovery.DiscoveryInterface, cl client.Client, obj client.Object, namespace string, ssa *harness.ServerSideApply, retry *retryPolicy) ([]string, error) {
 	_, _, err := testutils.Namespaced(dClient, obj, namespace)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	ctx := context.Background()
 	if timeout > 0 {
@@ -191,9 +142,24 @@ func doApply(test *testing.T, skipDelete bool, logger testutils.Logger, timeout
 		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
 		defer cancel()
 	}
-	updated, err := testutils.CreateOrUpdate(ctx, cl, obj, true)
-	if err != nil {
+	var updated bool
+	var warnings []string
+	err = retrying(ctx, retry, logger, testutils.ResourceID(obj), func(ctx context.Context) (err error) {
+		warnings, err = testutils.CaptureWarnings(ctx, cl, func(ctx context.Context) (err error) {
+			if ssa != nil && ssa.Enabled {
+				updated, err = testutils.ServerSideApply(ctx, cl, obj, ssa.FieldManager, ssa.ForceConflicts)
+			} else {
+				updated, err = testutils.CreateOrUpdate(ctx, cl, obj, true)
+			}
+			return err
+		})
 		return err
+	})
+	for _, warning := range warnings {
+		logger.Logf("%s: warning: %s", testutils.ResourceID(obj), warning)
+	}
+	if err != nil {
+		return warnings, err
 	}
 	// if the object was created, register cleanup
 	if !updated && !skipDelete {
@@ -231,12 +197,12 @@ func doApply(test *testing.T, skipDelete bool, logger testutils.Logger, timeout
 		action = "updated"
 	}
 	logger.Log(testutils.ResourceID(obj), action)
-	return nil
+	return warnings, nil
 }
 
 // Create applies all resources defined in the Apply list.
 func (s *Step) Create(test *testing.T, namespace string) []error {
-	cl, err := s.Client(true)
+	cl, err := s.Client(false)
 	if err != nil {
 		return []error{err}
 	}
@@ -247,17 +213,62 @@ func (s *Step) Create(test *testing.T, namespace string) []error {
 	}
 
 	errs := []error{}
+	stepWarnings := []string{}
+	// crds are the applied CRDs the following objects may depend on
+	crds := []client.Object{}
+
+	for _, apply := range sortApplies(s.Apply, s.Step != nil && s.Step.PreserveOrder) {
+		if len(crds) > 0 && !isCRD(apply.object) {
+			if err := s.establishCRDs(cl, crds); err != nil {
+				return append(errs, err)
+			}
+			crds = nil
+		}
 
-	for _, apply := range s.Apply {
-		err := doApply(test, s.SkipDelete, s.Logger, s.Timeout, dClient, cl, apply.object, namespace)
-		if err != nil && !apply.shouldFail {
+		ssa := s.ServerSideApply
+		if apply.serverSideApply != nil {
+			ssa = apply.serverSideApply
+		}
+		warnings, err := doApply(test, s.SkipDelete, s.Logger, s.Timeout, dClient, cl, apply.object, namespace, ssa, s.Retry)
+		for _, warning := range warnings {
+			s.Warnings = append(s.Warnings, report.Warning{Step: s.String(), Object: testutils.ResourceID(apply.object), Text: warning})
+		}
+		stepWarnings = append(stepWarnings, warnings...)
+		if apply.warnings != nil {
+			if err := apply.warnings.ValidateWarnings(warnings); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", testutils.ResourceID(apply.object), err))
+			}
+		}
+		shouldFail := apply.shouldFail || apply.expectedError != nil
+		if err != nil && !shouldFail {
 			errs = append(errs, err)
 		}
+		if err != nil && apply.expectedError != nil {
+			if err := apply.expectedError.ValidateError(err); err != nil {
+				errs = append(errs, err)
+			}
+		}
 		// if there was no error but we expected one
-		if err == nil && apply.shouldFail {
+		if err == nil && shouldFail {
 			// TODO: improve error message
 			errs = append(errs, errors.New("an error was expected but didn't happen"))
 		}
+		if err == nil && isCRD(apply.object) {
+			crds = append(crds, apply.object)
+		}
+	}
+
+	// the CRDs may be used by the following steps
+	if len(crds) > 0 {
+		if err := s.establishCRDs(cl, crds); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if s.Step != nil && s.Step.Warnings != nil {
+		if err := s.Step.Warnings.ValidateWarnings(stepWarnings); err != nil {
+			errs = append(errs, err)
+		}
 	}
 
 	return errs
@@ -272,24 +283,135 @@ func (s *Step) GetTimeout() int {
 	return timeout
 }
 
-func list(cl client.Client, gvk schema.GroupVersionKind, namespace string) ([]unstructured.Unstructured, error) {
+// GetHoldFor gets the duration the asserted state must hold for before the test step passes.
+func (s *Step) GetHoldFor() time.Duration {
+	if s.Assert != nil && s.Assert.HoldFor != nil {
+		return s.Assert.HoldFor.Duration
+	}
+	return 0
+}
+
+// selector restricts the objects which are compared against a document without a name.
+type selector struct {
+	labels labels.Selector
+	fields fields.Selector
+}
+
+// newSelector returns the selector for the expected object: the labels of the expected object
+// combined with the label selector of the options, and the field selector of the options.
+// expected may be nil to only validate the options.
+func newSelector(expected runtime.Object, options *harness.Options) (selector, error) {
+	sel := selector{labels: labels.Everything(), fields: fields.Everything()}
+
+	if expected != nil {
+		if m, err := meta.Accessor(expected); err == nil {
+			for key, value := range m.GetLabels() {
+				// labels which are not valid selector requirements, e.g. expressions, are still matched by the comparison
+				requirement, err := labels.NewRequirement(key, selection.Equals, []string{value})
+				if err != nil {
+					continue
+				}
+				sel.labels = sel.labels.Add(*requirement)
+			}
+		}
+	}
+
+	if options == nil {
+		return sel, nil
+	}
+
+	if options.LabelSelector != "" {
+		parsed, err := labels.Parse(options.LabelSelector)
+		if err != nil {
+			return sel, fmt.Errorf("parsing label selector %q: %w", options.LabelSelector, err)
+		}
+		requirements, _ := parsed.Requirements()
+		sel.labels = sel.labels.Add(requirements...)
+	}
+
+	if options.FieldSelector != "" {
+		parsed, err := fields.ParseSelector(options.FieldSelector)
+		if err != nil {
+			return sel, fmt.Errorf("parsing field selector %q: %w", options.FieldSelector, err)
+		}
+		sel.fields = parsed
+	}
+
+	return sel, nil
+}
+
+// listOptions returns the list options to only list the selected objects.
+func (sel selector) listOptions() []client.ListOption {
+	listOptions := []client.ListOption{}
+	if sel.labels != nil && !sel.labels.Empty() {
+		listOptions = append(listOptions, client.MatchingLabelsSelector{Selector: sel.labels})
+	}
+	if sel.fields != nil && !sel.fields.Empty() {
+		listOptions = append(listOptions, client.MatchingFieldsSelector{Selector: sel.fields})
+	}
+	return listOptions
+}
+
+func list(cl client.Client, gvk schema.GroupVersionKind, namespace string, sel selector) ([]unstructured.Unstructured, error) {
 	list := unstructured.UnstructuredList{}
 	list.SetGroupVersionKind(gvk)
 
-	listOptions := []client.ListOption{}
+	listOptions := sel.listOptions()
 	if namespace != "" {
 		listOptions = append(listOptions, client.InNamespace(namespace))
 	}
 
-	if err := cl.List(context.TODO(), &list, listOptions...); err != nil {
+	if err := testutils.WithMappingRefresh(cl, func() error {
+		return cl.List(context.TODO(), &list, listOptions...)
+	}); err != nil {
 		return []unstructured.Unstructured{}, err
 	}
 
 	return list.Items, nil
 }
 
+// getReferenced returns the live objects referenced by ref: the named object if it exists, or the objects matching
+// its labels and the selectors of options. The namespace of ref defaults to namespace for namespaced kinds.
+func getReferenced(cl client.Client, dClient discovery.DiscoveryInterface, ref harness.ObjectReference, namespace string, options *harness.Options) ([]unstructured.Unstructured, error) {
+	gvk := ref.GroupVersionKind()
+
+	obj := testutils.NewResource(gvk.GroupVersion().String(), gvk.Kind, ref.Name, "")
+	obj.SetLabels(ref.Labels)
+
+	objNs := namespace
+	if ref.Namespace != "" {
+		objNs = ref.Namespace
+	}
+
+	_, objNs, err := testutils.Namespaced(dClient, obj, objNs)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref.Name != "" {
+		actual := unstructured.Unstructured{}
+		actual.SetGroupVersionKind(gvk)
+		err := testutils.WithMappingRefresh(cl, func() error {
+			return cl.Get(context.TODO(), testutils.ObjectKey(obj), &actual)
+		})
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		return []unstructured.Unstructured{actual}, nil
+	}
+
+	sel, err := newSelector(obj, options)
+	if err != nil {
+		return nil, err
+	}
+	return list(cl, gvk, objNs, sel)
+}
+
 // CheckResource checks if the expected resource's state in Kubernetes is correct.
-func (s *Step) CheckResource(expected runtime.Object, namespace string, strategyFactory testutils.ArrayComparisonStrategyFactory) []error {
+// If the expected resource has no name, only the resources selected by its labels and the selectors of options are checked.
+func (s *Step) CheckResource(expected runtime.Object, namespace string, strategyFactory testutils.ArrayComparisonStrategyFactory, options *harness.Options) []error {
 	cl, err := s.Client(false)
 	if err != nil {
 		return []error{err}
@@ -315,15 +437,21 @@ func (s *Step) CheckResource(expected runtime.Object, namespace string, strategy
 		actual := unstructured.Unstructured{}
 		actual.SetGroupVersionKind(gvk)
 
-		err = cl.Get(context.TODO(), client.ObjectKey{
-			Namespace: namespace,
-			Name:      name,
-		}, &actual)
+		err = testutils.WithMappingRefresh(cl, func() error {
+			return cl.Get(context.TODO(), client.ObjectKey{
+				Namespace: namespace,
+				Name:      name,
+			}, &actual)
+		})
 
 		actuals = append(actuals, actual)
 	} else {
-		actuals, err = list(cl, gvk, namespace)
-		if len(actuals) == 0 {
+		var sel selector
+		sel, err = newSelector(expected, options)
+		if err == nil {
+			actuals, err = list(cl, gvk, namespace, sel)
+		}
+		if err == nil && len(actuals) == 0 {
 			testErrors = append(testErrors, fmt.Errorf("no resources matched of kind: %s", gvk.String()))
 		}
 	}
@@ -331,6 +459,14 @@ func (s *Step) CheckResource(expected runtime.Object, namespace string, strategy
 		return append(testErrors, err)
 	}
 
+	return append(testErrors, compareResources(expected, actuals, strategyFactory)...)
+}
+
+// compareResources compares expected with actuals, it returns no error if any of actuals is a superset of expected and
+// the differences with all actuals otherwise.
+func compareResources(expected runtime.Object, actuals []unstructured.Unstructured, strategyFactory testutils.ArrayComparisonStrategyFactory) []error {
+	testErrors := []error{}
+
 	expectedObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(expected)
 	if err != nil {
 		return append(testErrors, err)
@@ -362,8 +498,93 @@ func (s *Step) CheckResource(expected runtime.Object, namespace string, strategy
 	return testErrors
 }
 
+// CheckResourceCount checks if the number of resources in Kubernetes which match the expected resource satisfies the count of options.
+func (s *Step) CheckResourceCount(expected runtime.Object, namespace string, strategyFactory testutils.ArrayComparisonStrategyFactory, options *harness.Options) []error {
+	if options == nil || options.Count == nil {
+		return s.CheckResource(expected, namespace, strategyFactory, options)
+	}
+
+	cl, err := s.Client(false)
+	if err != nil {
+		return []error{err}
+	}
+
+	dClient, err := s.DiscoveryClient()
+	if err != nil {
+		return []error{err}
+	}
+
+	name, namespace, err := testutils.Namespaced(dClient, expected, namespace)
+	if err != nil {
+		return []error{err}
+	}
+
+	gvk := expected.GetObjectKind().GroupVersionKind()
+
+	var actuals []unstructured.Unstructured
+
+	if name != "" {
+		actual := unstructured.Unstructured{}
+		actual.SetGroupVersionKind(gvk)
+
+		if err := testutils.WithMappingRefresh(cl, func() error {
+			return cl.Get(context.TODO(), client.ObjectKey{
+				Namespace: namespace,
+				Name:      name,
+			}, &actual)
+		}); err != nil {
+			if !k8serrors.IsNotFound(err) {
+				return []error{err}
+			}
+		} else {
+			actuals = []unstructured.Unstructured{actual}
+		}
+	} else {
+		sel, err := newSelector(expected, options)
+		if err != nil {
+			return []error{err}
+		}
+		actuals, err = list(cl, gvk, namespace, sel)
+		if err != nil {
+			return []error{err}
+		}
+	}
+
+	expectedObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(expected)
+	if err != nil {
+		return []error{err}
+	}
+
+	matched := []string{}
+	for _, actual := range actuals {
+		if err := testutils.IsSubset(expectedObj, actual.UnstructuredContent(), "/", strategyFactory); err == nil {
+			matched = append(matched, actual.GetName())
+		}
+	}
+
+	if violation := countViolation(options.Count, len(matched)); violation != "" {
+		return []error{fmt.Errorf("resource %s: %s, found %d matching resources %v", testutils.ResourceID(expected), violation, len(matched), matched)}
+	}
+	return nil
+}
+
+// countViolation returns a description of the constraint of count which is violated by matched objects,
+// or an empty string if count is satisfied.
+func countViolation(count *harness.Count, matched int) string {
+	switch {
+	case count.Exact != nil && matched != *count.Exact:
+		return fmt.Sprintf("expected exactly %d matching resources", *count.Exact)
+	case count.Min != nil && matched < *count.Min:
+		return fmt.Sprintf("expected at least %d matching resources", *count.Min)
+	case count.Max != nil && matched > *count.Max:
+		return fmt.Sprintf("expected at most %d matching resources", *count.Max)
+	}
+	return ""
+}
+
 // CheckResourceAbsent checks if the expected resource's state is absent in Kubernetes.
-func (s *Step) CheckResourceAbsent(expected runtime.Object, namespace string) error {
+// If the expected resource has no name, only the resources selected by its labels and the selectors of options are checked.
+func (s *Step) CheckResourceAbsent(expected runtime.Object, namespace string, options *harness.Options) error {
 	cl, err := s.Client(false)
 	if err != nil {
 		return err
@@ -387,10 +608,12 @@ func (s *Step) CheckResourceAbsent(expected runtime.Object, namespace string) er
 		actual := unstructured.Unstructured{}
 		actual.SetGroupVersionKind(gvk)
 
-		if err := cl.Get(context.TODO(), client.ObjectKey{
-			Namespace: namespace,
-			Name:      name,
-		}, &actual); err != nil {
+		if err := testutils.WithMappingRefresh(cl, func() error {
+			return cl.Get(context.TODO(), client.ObjectKey{
+				Namespace: namespace,
+				Name:      name,
+			}, &actual)
+		}); err != nil {
 			if k8serrors.IsNotFound(err) {
 				return nil
 			}
@@ -400,7 +623,11 @@ func (s *Step) CheckResourceAbsent(expected runtime.Object, namespace string) er
 
 		actuals = []unstructured.Unstructured{actual}
 	} else {
-		actuals, err = list(cl, gvk, namespace)
+		sel, err := newSelector(expected, options)
+		if err != nil {
+			return err
+		}
+		actuals, err = list(cl, gvk, namespace, sel)
 		if err != nil {
 			return err
 		}
@@ -421,10 +648,44 @@ func (s *Step) CheckResourceAbsent(expected runtime.Object, namespace string) er
 	if len(unexpectedObjects) == 0 {
 		return nil
 	}
-	if len(unexpectedObjects) == 1 {
-		return fmt.Errorf("resource %s %s matched error assertion", unexpectedObjects[0].GroupVersionKind(), unexpectedObjects[0].GetName())
+	return &errorAssertionError{objects: unexpectedObjects}
+}
+
+// errorAssertionError is returned if objects matching an error assertion were observed.
+type errorAssertionError struct {
+	objects []unstructured.Unstructured
+}
+
+func (e *errorAssertionError) Error() string {
+	if len(e.objects) == 1 {
+		return fmt.Sprintf("resource %s %s matched error assertion", e.objects[0].GroupVersionKind(), e.objects[0].GetName())
+	}
+	return fmt.Sprintf("resource %s %s (and %d other resources) matched error assertion", e.objects[0].GroupVersionKind(), e.objects[0].GetName(), len(e.objects)-1)
+}
+
+// snapshot returns the state of the first offending object at the time it matched the error assertion.
+func (e *errorAssertionError) snapshot() (string, error) {
+	var buf bytes.Buffer
+	if err := testutils.MarshalObject(&e.objects[0], &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// errorAssertionFailure returns the errors to fail a step with if err contains an errorAssertionError, or nil otherwise.
+// The snapshot of the offending object is reported along with the error.
+func errorAssertionFailure(err []error) []error {
+	for i := range err {
+		var matched *errorAssertionError
+		if errors.As(err[i], &matched) {
+			snapshot, snapshotErr := matched.snapshot()
+			if snapshotErr != nil {
+				return []error{snapshotErr, matched}
+			}
+			return []error{errors.New(snapshot), matched}
+		}
 	}
-	return fmt.Errorf("resource %s %s (and %d other resources) matched error assertion", unexpectedObjects[0].GroupVersionKind(), unexpectedObjects[0].GetName(), len(unexpectedObjects)-1)
+	return nil
 }
 
 // pathMatches checks if the given path matches the pattern.
@@ -476,7 +737,7 @@ func NewStrategyFactory(a asserts) func(path string) testutils.ArrayComparisonSt
 // the errors returned can be a a failure of executing the command or the failure of the command executed.
 func (s *Step) CheckAssertCommands(ctx context.Context, namespace string, commands []harness.TestAssertCommand, timeout int) []error {
 	testErrors := []error{}
-	if _, err := testutils.RunAssertCommands(ctx, s.Logger, namespace, commands, "", timeout, s.Kubeconfig); err != nil {
+	if _, err := testutils.RunAssertCommands(ctx, s.Logger, namespace, commands, "", timeout, s.Kubeconfig, s.Variables); err != nil {
 		testErrors = append(testErrors, err)
 	}
 	return testErrors
@@ -488,15 +749,22 @@ func (s *Step) Check(namespace string, timeout int) []error {
 
 	for _, expected := range s.Asserts {
 		strategyFactory := NewStrategyFactory(expected)
-		testErrors = append(testErrors, s.CheckResource(expected.object, namespace, strategyFactory)...)
+		if expected.options != nil && expected.options.Count != nil {
+			testErrors = append(testErrors, s.CheckResourceCount(expected.object, namespace, strategyFactory, expected.options)...)
+		} else {
+			testErrors = append(testErrors, s.CheckResource(expected.object, namespace, strategyFactory, expected.options)...)
+		}
 	}
 
 	if s.Assert != nil {
 		testErrors = append(testErrors, s.CheckAssertCommands(context.TODO(), namespace, s.Assert.Commands, timeout)...)
+		testErrors = append(testErrors, s.CheckAccess(namespace, s.Assert.Access)...)
+		testErrors = append(testErrors, s.CheckAbsent(namespace, s.Assert.Absent)...)
+		testErrors = append(testErrors, s.CheckOwnership(namespace, s.Assert.Ownership)...)
 	}
 
 	for _, expected := range s.Errors {
-		if testError := s.CheckResourceAbsent(expected, namespace); testError != nil {
+		if testError := s.CheckResourceAbsent(expected.object, namespace, expected.options); testError != nil {
 			testErrors = append(testErrors, testError)
 		}
 	}
@@ -510,43 +778,140 @@ func (s *Step) Check(namespace string, timeout int) []error {
 func (s *Step) Run(test *testing.T, namespace string) []error {
 	s.Logger.Log("starting test step", s.String())
 
+	if err := s.expandVariables(namespace); err != nil {
+		return []error{err}
+	}
+
 	if err := s.DeleteExisting(namespace); err != nil {
 		return []error{err}
 	}
 
+	// watch the asserted objects and, if the error assertions are invariants, the objects matching them for the
+	// whole step
+	notifier := s.watchChanges(namespace)
+	defer notifier.stop()
+
 	testErrors := []error{}
 
-	if s.Step != nil {
-		for _, command := range s.Step.Commands {
-			if command.Background {
-				s.Logger.Log("background commands are not allowed for steps and will be run in foreground")
-				command.Background = false
+	// the step fails as soon as an invariant error assertion is violated by any of its phases
+	phases := []func() []error{
+		func() []error {
+			if s.Step == nil {
+				return nil
 			}
-		}
-		if _, err := testutils.RunCommands(context.TODO(), s.Logger, namespace, s.Step.Commands, s.Dir, s.Timeout, s.Kubeconfig); err != nil {
-			testErrors = append(testErrors, err)
+			for _, command := range s.Step.Commands {
+				if command.Background {
+					s.Logger.Log("background commands are not allowed for steps and will be run in foreground")
+					command.Background = false
+				}
+			}
+			if _, err := testutils.RunCommands(context.TODO(), s.Logger, namespace, s.Step.Commands, s.Dir, s.Timeout, s.Kubeconfig, s.Variables); err != nil {
+				return []error{err}
+			}
+			return nil
+		},
+		func() []error { return s.Create(test, namespace) },
+		func() []error { return s.Patch(namespace) },
+		func() []error { return s.Replace(namespace) },
+		func() []error { return s.DryRun(namespace) },
+	}
+	for _, phase := range phases {
+		testErrors = append(testErrors, phase()...)
+		if err := notifier.err(); err != nil {
+			return errorAssertionFailure([]error{err})
 		}
 	}
 
-	testErrors = append(testErrors, s.Create(test, namespace)...)
-
 	if len(testErrors) != 0 {
 		return testErrors
 	}
 
+	// waiting for the objects to be ready is interrupted by a violation
+	errs := s.WaitForReady(notifier.aborted, namespace)
+	if err := notifier.err(); err != nil {
+		return errorAssertionFailure([]error{err})
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+
+	if s.Step != nil {
+		if err := s.Capture(namespace, s.Step.Capture); err != nil {
+			return []error{err}
+		}
+		if err := notifier.err(); err != nil {
+			return errorAssertionFailure([]error{err})
+		}
+	}
+
 	timeoutF := float64(s.GetTimeout())
+	holdFor := s.GetHoldFor()
 	start := time.Now()
 
-	for elapsed := 0.0; elapsed < timeoutF; elapsed = time.Since(start).Seconds() {
-		testErrors = s.Check(namespace, int(timeoutF-elapsed))
+	// holdingSince is the time since which the checks pass, the timeout does not interrupt the hold period.
+	var holdingSince time.Time
 
-		if len(testErrors) == 0 {
+	for elapsed := 0.0; elapsed < timeoutF || !holdingSince.IsZero(); elapsed = time.Since(start).Seconds() {
+		remaining := timeoutF - elapsed
+		if remaining < holdFor.Seconds() {
+			remaining = holdFor.Seconds()
+		}
+		if err := notifier.err(); err != nil {
+			testErrors = []error{err}
 			break
 		}
-		if hasTimeoutErr(testErrors) {
+
+		testErrors = s.Check(namespace, int(math.Ceil(remaining)))
+
+		if notifier.violated(testErrors) {
 			break
 		}
-		time.Sleep(time.Second)
+
+		if len(testErrors) == 0 {
+			if holdingSince.IsZero() {
+				holdingSince = time.Now()
+				if holdFor > 0 {
+					s.Logger.Logf("asserted state reached, verifying that it holds for %v", holdFor)
+				}
+			}
+			if time.Since(holdingSince) >= holdFor {
+				break
+			}
+		} else {
+			if !holdingSince.IsZero() {
+				s.Logger.Logf("asserted state did not hold after %v", time.Since(holdingSince).Round(time.Second))
+				holdingSince = time.Time{}
+			}
+			if hasTimeoutErr(testErrors) {
+				break
+			}
+		}
+
+		// wait for a change, but not beyond the timeout or the end of the hold period
+		waitFor := time.Duration(timeoutF*float64(time.Second)) - time.Since(start)
+		if !holdingSince.IsZero() {
+			waitFor = holdFor - time.Since(holdingSince)
+		}
+		notifier.wait(waitFor)
+	}
+
+	// invariant error assertions must hold for the whole step, even while the asserts are being checked
+	if err := notifier.err(); err != nil {
+		testErrors = []error{err}
+	}
+	if failure := errorAssertionFailure(testErrors); failure != nil {
+		testErrors = failure
+	}
+
+	if len(testErrors) == 0 && s.Assert != nil {
+		if err := s.Capture(namespace, s.Assert.Capture); err != nil {
+			testErrors = append(testErrors, err)
+		}
+	}
+
+	// the owners are deleted last, their deletion cascades to the asserted objects
+	if len(testErrors) == 0 && s.Assert != nil {
+		testErrors = append(testErrors, s.CascadeOwnership(namespace, s.Assert.Ownership)...)
 	}
 
 	// all is good
@@ -565,7 +930,7 @@ func (s *Step) Run(test *testing.T, namespace string) []error {
 			s.Logger.Log("skipping invalid assertion collector")
 			continue
 		}
-		_, err := testutils.RunCommand(context.TODO(), namespace, *collector.Command(), s.Dir, s.Logger, s.Logger, s.Logger, s.Timeout, s.Kubeconfig)
+		_, err := testutils.RunCommand(context.TODO(), namespace, *collector.Command(), s.Dir, s.Logger, s.Logger, s.Logger, s.Timeout, s.Kubeconfig, s.Variables)
 		if err != nil {
 			s.Logger.Log("post assert collector failure: %s", err)
 		}
@@ -574,6 +939,17 @@ func (s *Step) Run(test *testing.T, namespace string) []error {
 	return testErrors
 }
 
+// RunFinally runs the finally commands of the step, with the variables captured by the steps which ran.
+func (s *Step) RunFinally(namespace string) error {
+	if s.Step == nil || len(s.Step.Finally) == 0 {
+		return nil
+	}
+
+	s.Logger.Log("running finally commands of test step", s.String())
+	_, err := testutils.RunCommands(context.TODO(), s.Logger, namespace, s.Step.Finally, s.Dir, s.Timeout, s.Kubeconfig, s.Variables)
+	return err
+}
+
 // String implements the string interface, returning the name of the test step.
 func (s *Step) String() string {
 	return fmt.Sprintf("%d-%s", s.Index, s.Name)
@@ -601,6 +977,18 @@ func (s *Step) LoadYAML(file string) error {
 		obj := assert.object
 		if obj.GetObjectKind().GroupVersionKind().Kind == "TestAssert" {
 			if testAssert, ok := obj.DeepCopyObject().(*harness.TestAssert); ok {
+				if err := validateCaptures(testAssert.Capture); err != nil {
+					return fmt.Errorf("failed to validate TestAssert object from %s: %v", file, err)
+				}
+				if err := validateAccessChecks(testAssert.Access); err != nil {
+					return fmt.Errorf("failed to validate TestAssert object from %s: %v", file, err)
+				}
+				if err := validateAbsent(testAssert.Absent); err != nil {
+					return fmt.Errorf("failed to validate TestAssert object from %s: %v", file, err)
+				}
+				if err := validateOwnershipChecks(testAssert.Ownership); err != nil {
+					return fmt.Errorf("failed to validate TestAssert object from %s: %v", file, err)
+				}
 				s.Assert = testAssert
 			} else {
 				return fmt.Errorf("failed to load TestAssert object from %s: it contains an object of type %T", file, obj)
@@ -634,6 +1022,20 @@ func (s *Step) LoadYAML(file string) error {
 				exKubeconfig := env.Expand(s.Step.Kubeconfig)
 				s.Kubeconfig = cleanPath(exKubeconfig, s.Dir)
 			}
+			s.Impersonate = s.Step.Impersonate
+			if s.Step.Templating != nil {
+				s.Templating = s.Step.Templating
+			}
+			if s.Step.ServerSideApply != nil {
+				s.ServerSideApply = s.Step.ServerSideApply
+			}
+			if s.Step.Retry != nil {
+				retry, err := compileRetryPolicy(s.Step.Retry)
+				if err != nil {
+					return fmt.Errorf("failed to validate TestStep object from %s: invalid retry: %v", file, err)
+				}
+				s.Retry = retry
+			}
 		} else {
 			applies = append(applies, apply)
 		}
@@ -649,9 +1051,50 @@ func (s *Step) LoadYAML(file string) error {
 				return fmt.Errorf("step %q apply path %s: %w", s.Name, exApply, err)
 			}
 			for _, a := range aa {
-				applies = append(applies, apply{object: a, shouldFail: applyPath.ShouldFail})
+				applies = append(applies, apply{object: a, shouldFail: applyPath.ShouldFail, serverSideApply: applyPath.ServerSideApply, expectedError: applyPath.ExpectedError, warnings: applyPath.Warnings, waitForReady: applyPath.WaitForReady})
 			}
 		}
+		// process configured step patches
+		for _, p := range s.Step.Patch {
+			loaded, err := loadPatch(p, s.Dir)
+			if err != nil {
+				return fmt.Errorf("step %q patch of %s: %w", s.Name, p.Object.Name, err)
+			}
+			s.Patches = append(s.Patches, loaded)
+		}
+		// process configured step replaces
+		for _, replacePath := range s.Step.Replace {
+			exReplace := env.Expand(replacePath.File)
+			rr, err := ObjectsFromPath(exReplace, s.Dir)
+			if err != nil {
+				return fmt.Errorf("step %q replace path %s: %w", s.Name, exReplace, err)
+			}
+			for _, r := range rr {
+				s.Replaces = append(s.Replaces, apply{object: r, shouldFail: replacePath.ShouldFail, expectedError: replacePath.ExpectedError})
+			}
+		}
+		// process configured step dry-runs
+		for _, dryRunPath := range s.Step.DryRun {
+			exDryRun := env.Expand(dryRunPath.File)
+			objects, err := ObjectsFromPath(exDryRun, s.Dir)
+			if err != nil {
+				return fmt.Errorf("step %q dry-run path %s: %w", s.Name, exDryRun, err)
+			}
+			var expected []client.Object
+			if dryRunPath.Expected != "" {
+				exExpected := env.Expand(dryRunPath.Expected)
+				if expected, err = ObjectsFromPath(exExpected, s.Dir); err != nil {
+					return fmt.Errorf("step %q dry-run expected path %s: %w", s.Name, exExpected, err)
+				}
+			}
+			s.DryRuns = append(s.DryRuns, dryRun{
+				objects:       objects,
+				expected:      expected,
+				shouldFail:    dryRunPath.ShouldFail,
+				expectedError: dryRunPath.ExpectedError,
+				warnings:      dryRunPath.Warnings,
+			})
+		}
 		// process configured step asserts
 		for _, assertPath := range s.Step.Assert {
 			exAssert := env.Expand(assertPath.File)
@@ -660,17 +1103,19 @@ func (s *Step) LoadYAML(file string) error {
 				return fmt.Errorf("step %q assert path %s: %w", s.Name, exAssert, err)
 			}
 			for _, a := range assert {
-				asserties = append(asserties, asserts{object: a})
+				asserties = append(asserties, asserts{object: a, options: assertPath.Options})
 			}
 		}
 		// process configured errors
 		for _, errorPath := range s.Step.Error {
-			exError := env.Expand(errorPath)
+			exError := env.Expand(errorPath.File)
 			errObjs, err := ObjectsFromPath(exError, s.Dir)
 			if err != nil {
 				return fmt.Errorf("step %q error path %s: %w", s.Name, exError, err)
 			}
-			s.Errors = append(s.Errors, errObjs...)
+			for _, e := range errObjs {
+				s.Errors = append(s.Errors, asserts{object: e, options: errorPath.Options})
+			}
 		}
 	}
 
@@ -693,7 +1138,9 @@ func (s *Step) populateObjectsByFileName(fileName string, objects []client.Objec
 			s.Asserts = append(s.Asserts, asserts{object: obj})
 		}
 	case "errors":
-		s.Errors = append(s.Errors, objects...)
+		for _, obj := range objects {
+			s.Errors = append(s.Errors, asserts{object: obj})
+		}
 	default:
 		if s.Name == "" {
 			if len(matches) > 2 {
@@ -765,14 +1212,88 @@ func validateTestStep(ts *harness.TestStep, baseDir string) error {
 		if _, err := os.Stat(path); os.IsNotExist(err) {
 			return fmt.Errorf("referenced file in Assert does not exist: %s", path)
 		}
+		if assert.Options != nil && assert.Options.Count != nil {
+			if err := validateCount(assert.Options.Count); err != nil {
+				return fmt.Errorf("invalid count for Assert %s: %w", assert.File, err)
+			}
+		}
+		if _, err := newSelector(nil, assert.Options); err != nil {
+			return fmt.Errorf("invalid selector for Assert %s: %w", assert.File, err)
+		}
 	}
 	// Check if referenced files in  Error exist
 	for _, errorPath := range ts.Error {
-		path := filepath.Join(baseDir, errorPath)
+		path := filepath.Join(baseDir, errorPath.File)
 		if _, err := os.Stat(path); os.IsNotExist(err) {
 			return fmt.Errorf("referenced file in Error does not exist: %s", path)
 		}
+		if errorPath.Options != nil && errorPath.Options.Count != nil {
+			return fmt.Errorf("count is not supported for Error %s, errors files match any number of objects", errorPath.File)
+		}
+		if _, err := newSelector(nil, errorPath.Options); err != nil {
+			return fmt.Errorf("invalid selector for Error %s: %w", errorPath.File, err)
+		}
+	}
+
+	// Check if referenced files in Replace exist
+	for _, replace := range ts.Replace {
+		path := filepath.Join(baseDir, replace.File)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return fmt.Errorf("referenced file in Replace does not exist: %s", path)
+		}
+	}
+
+	// Check if referenced files in DryRun exist
+	for _, dryRun := range ts.DryRun {
+		for _, file := range []string{dryRun.File, dryRun.Expected} {
+			if file == "" {
+				continue
+			}
+			path := filepath.Join(baseDir, file)
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				return fmt.Errorf("referenced file in DryRun does not exist: %s", path)
+			}
+		}
+	}
+
+	if err := validateDeletions(ts.Delete); err != nil {
+		return err
+	}
+
+	if err := validatePatches(ts.Patch, baseDir); err != nil {
+		return err
+	}
+
+	if err := validateCaptures(ts.Capture); err != nil {
+		return err
+	}
+
+	if ts.Impersonate != nil {
+		if err := validateImpersonation(ts.Impersonate); err != nil {
+			return fmt.Errorf("invalid impersonate: %w", err)
+		}
 	}
 
+	for i, command := range ts.Finally {
+		if command.Background {
+			return fmt.Errorf("finally command %d must not run in the background", i)
+		}
+	}
+
+	return nil
+}
+
+func validateCount(count *harness.Count) error {
+	for _, n := range []*int{count.Exact, count.Min, count.Max} {
+		if n != nil && *n < 0 {
+			return fmt.Errorf("count must not be negative")
+		}
+	}
+	if count.Exact != nil && (count.Min != nil || count.Max != nil) {
+		return fmt.Errorf("exact can not be combined with min or max")
+	}
+	if count.Min != nil && count.Max != nil && *count.Min > *count.Max {
+		return fmt.Errorf("min %d is greater than max %d", *count.Min, *count.Max)
+	}
 	return nil
 }
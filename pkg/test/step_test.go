@@ -2,6 +2,13 @@ package test
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,6 +25,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	"github.com/kyverno/kuttl/pkg/report"
 	testutils "github.com/kyverno/kuttl/pkg/test/utils"
 )
 
@@ -95,6 +103,404 @@ func TestStepCreate(t *testing.T) {
 	assert.True(t, k8serrors.IsNotFound(cl.Get(context.TODO(), testutils.ObjectKey(actual), actual)))
 }
 
+// Verify that server-side apply removes the fields dropped from a manifest and reports conflicts with other field
+// managers, like GitOps tools applying objects reconciled by a controller.
+func TestStepCreateServerSideApply(t *testing.T) {
+	kuttl := &harness.ServerSideApply{Enabled: true}
+	argo := &harness.ServerSideApply{Enabled: true, FieldManager: "argocd-controller"}
+	forcedArgo := &harness.ServerSideApply{Enabled: true, FieldManager: "argocd-controller", ForceConflicts: true}
+	controller := &harness.ServerSideApply{Enabled: true, FieldManager: "controller"}
+
+	type application struct {
+		ssa         *harness.ServerSideApply
+		labels      map[string]string
+		expectedErr string
+	}
+
+	for _, test := range []struct {
+		name           string
+		applications   []application
+		expectedLabels map[string]string
+	}{
+		{
+			name: "fields removed from the manifest are removed",
+			applications: []application{
+				{ssa: kuttl, labels: map[string]string{"app": "hello", "tier": "frontend"}},
+				{ssa: kuttl, labels: map[string]string{"app": "hello"}},
+			},
+			expectedLabels: map[string]string{"app": "hello"},
+		},
+		{
+			name: "merge patch keeps fields removed from the manifest",
+			applications: []application{
+				{labels: map[string]string{"app": "hello", "tier": "frontend"}},
+				{labels: map[string]string{"app": "hello"}},
+			},
+			expectedLabels: map[string]string{"app": "hello", "tier": "frontend"},
+		},
+		{
+			name: "fields owned by other field managers are kept",
+			applications: []application{
+				{ssa: controller, labels: map[string]string{"reconciled": "true"}},
+				{ssa: argo, labels: map[string]string{"app": "hello"}},
+				{ssa: argo, labels: map[string]string{}},
+			},
+			expectedLabels: map[string]string{"reconciled": "true"},
+		},
+		{
+			name: "conflict with the controller",
+			applications: []application{
+				{ssa: controller, labels: map[string]string{"app": "reconciled"}},
+				{ssa: argo, labels: map[string]string{"app": "hello"}, expectedErr: `Apply failed with 1 conflict: conflict with "controller": .metadata.labels.app`},
+			},
+			expectedLabels: map[string]string{"app": "reconciled"},
+		},
+		{
+			name: "force conflicts takes the ownership",
+			applications: []application{
+				{ssa: controller, labels: map[string]string{"app": "reconciled"}},
+				{ssa: forcedArgo, labels: map[string]string{"app": "hello"}},
+				{ssa: argo, labels: map[string]string{}},
+			},
+			expectedLabels: map[string]string{},
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			cl := &applyClient{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()}
+
+			for _, application := range test.applications {
+				step := Step{
+					Logger:          testutils.NewTestLogger(t, ""),
+					Apply:           []apply{{object: testutils.WithLabels(t, testutils.NewPod("hello", ""), application.labels), serverSideApply: application.ssa}},
+					Client:          func(bool) (client.Client, error) { return cl, nil },
+					DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+					SkipDelete:      true,
+				}
+
+				errs := step.Create(t, testNamespace)
+				if application.expectedErr != "" {
+					assert.Len(t, errs, 1)
+					assert.True(t, k8serrors.IsConflict(errs[0]))
+					assert.EqualError(t, errs[0], application.expectedErr)
+				} else {
+					assert.Equal(t, []error{}, errs)
+				}
+			}
+
+			actual := testutils.NewPod("hello", testNamespace)
+			assert.Nil(t, cl.Get(context.TODO(), testutils.ObjectKey(actual), actual))
+			labels := actual.GetLabels()
+			if labels == nil {
+				labels = map[string]string{}
+			}
+			assert.Equal(t, test.expectedLabels, labels)
+		})
+	}
+}
+
+// Verify that the server-side apply configuration of a file overrides the one of the step.
+func TestStepCreateServerSideApplyOverride(t *testing.T) {
+	cl := &applyClient{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()}
+
+	step := Step{
+		Logger: testutils.NewTestLogger(t, ""),
+		Apply: []apply{
+			{object: testutils.NewPod("default-manager", "")},
+			{object: testutils.NewPod("file-manager", ""), serverSideApply: &harness.ServerSideApply{Enabled: true, FieldManager: "flux"}},
+			{object: testutils.NewPod("merge-patch", ""), serverSideApply: &harness.ServerSideApply{}},
+		},
+		ServerSideApply: &harness.ServerSideApply{Enabled: true},
+		Client:          func(bool) (client.Client, error) { return cl, nil },
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+	}
+
+	assert.Equal(t, []error{}, step.Create(t, testNamespace))
+	assert.Equal(t, map[string]string{
+		"default-manager": testutils.DefaultFieldManager,
+		"file-manager":    "flux",
+	}, cl.appliedBy)
+}
+
+// Verify that the errors of applies expected to fail are matched against the expected error.
+func TestStepCreateExpectedError(t *testing.T) {
+	denied := &k8serrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    400,
+		Reason:  metav1.StatusReasonBadRequest,
+		Message: `admission webhook "validate.kyverno.svc-fail" denied the request: label app is required`,
+	}}
+
+	for _, test := range []struct {
+		name          string
+		err           error
+		expectedError *harness.ExpectedError
+		expectedErrs  []string
+	}{
+		{
+			name: "expected error",
+			err:  denied,
+			expectedError: &harness.ExpectedError{
+				Reason:  metav1.StatusReasonBadRequest,
+				Message: &harness.ExpectedOutput{MatchType: harness.MatchContains, ExpectedValue: `webhook "validate.kyverno.svc-fail" denied`},
+			},
+		},
+		{
+			name:          "unexpected error",
+			err:           denied,
+			expectedError: &harness.ExpectedError{Reason: metav1.StatusReasonForbidden},
+			expectedErrs:  []string{fmt.Sprintf("unexpected error %q: expected reason Forbidden, got: BadRequest", denied)},
+		},
+		{
+			name:          "no error",
+			expectedError: &harness.ExpectedError{Reason: metav1.StatusReasonForbidden},
+			expectedErrs:  []string{"an error was expected but didn't happen"},
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			cl := &rejectingClient{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), err: test.err}
+
+			step := Step{
+				Logger:          testutils.NewTestLogger(t, ""),
+				Apply:           []apply{{object: testutils.NewPod("hello", ""), expectedError: test.expectedError}},
+				Client:          func(bool) (client.Client, error) { return cl, nil },
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+				SkipDelete:      true,
+			}
+
+			errs := []string{}
+			for _, err := range step.Create(t, testNamespace) {
+				errs = append(errs, err.Error())
+			}
+			assert.ElementsMatch(t, test.expectedErrs, errs)
+		})
+	}
+}
+
+// rejectingClient rejects the creation of objects with err, if set.
+type rejectingClient struct {
+	client.Client
+	err error
+}
+
+func (c *rejectingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func TestStepCreateWarnings(t *testing.T) {
+	deprecated := "policy/v1beta1 PodDisruptionBudget is deprecated in v1.21+, unavailable in v1.25+"
+	audited := "policy require-labels/check-for-labels fail: label app is required"
+
+	for _, test := range []struct {
+		name         string
+		step         *harness.TestStep
+		warnings     *harness.Warnings
+		expectedErrs []string
+	}{
+		{
+			name:     "expected warnings",
+			step:     &harness.TestStep{Warnings: &harness.Warnings{Expected: []harness.ExpectedOutput{{MatchType: harness.MatchContains, ExpectedValue: "is deprecated"}}}},
+			warnings: &harness.Warnings{Expected: []harness.ExpectedOutput{{MatchType: harness.MatchContains, ExpectedValue: "require-labels"}}},
+		},
+		{
+			name:     "forbidden warnings",
+			step:     &harness.TestStep{Warnings: &harness.Warnings{Forbidden: []harness.ExpectedOutput{{MatchType: harness.MatchWildcard, ExpectedValue: "*unknown field*"}}}},
+			warnings: &harness.Warnings{Forbidden: []harness.ExpectedOutput{{MatchType: harness.MatchContains, ExpectedValue: "is deprecated"}}},
+		},
+		{
+			name:         "missing warning of the object",
+			warnings:     &harness.Warnings{Expected: []harness.ExpectedOutput{{MatchType: harness.MatchContains, ExpectedValue: "is deprecated"}}},
+			expectedErrs: []string{fmt.Sprintf(`Pod:world/hello: unexpected warnings %q: expected a warning matching Contains "is deprecated"`, []string{audited})},
+		},
+		{
+			name:         "forbidden warning of the step",
+			step:         &harness.TestStep{Warnings: &harness.Warnings{Forbidden: []harness.ExpectedOutput{{MatchType: harness.MatchContains, ExpectedValue: "require-labels"}}}},
+			expectedErrs: []string{fmt.Sprintf(`unexpected warnings %q: unexpected warning matching Contains "require-labels": %s`, []string{audited, deprecated}, audited)},
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			cl := &warningClient{
+				Client:   fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(),
+				warnings: map[string][]string{"hello": {audited}, "other": {deprecated}},
+			}
+
+			step := Step{
+				Name:  "install",
+				Index: 1,
+				Step:  test.step,
+				Apply: []apply{
+					{object: testutils.NewPod("hello", ""), warnings: test.warnings},
+					{object: testutils.NewPod("other", "")},
+				},
+				Logger:          testutils.NewTestLogger(t, ""),
+				Client:          func(bool) (client.Client, error) { return cl, nil },
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+				SkipDelete:      true,
+			}
+
+			errs := []string{}
+			for _, err := range step.Create(t, testNamespace) {
+				errs = append(errs, err.Error())
+			}
+			assert.ElementsMatch(t, test.expectedErrs, errs)
+			assert.Equal(t, []report.Warning{
+				{Step: "1-install", Object: "Pod:world/hello", Text: audited},
+				{Step: "1-install", Object: "Pod:world/other", Text: deprecated},
+			}, step.Warnings)
+		})
+	}
+}
+
+// warningClient returns warnings for the objects created, by name.
+type warningClient struct {
+	client.Client
+	warnings map[string][]string
+
+	captured []string
+}
+
+func (c *warningClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.captured = append(c.captured, c.warnings[obj.GetName()]...)
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *warningClient) CaptureWarnings(ctx context.Context, fn func(ctx context.Context) error) ([]string, error) {
+	c.captured = nil
+	err := fn(ctx)
+	return c.captured, err
+}
+
+// applyClient emulates server-side apply on top of the fake client, which does not support apply patches. The fields
+// of maps are owned individually by the field managers, lists and other values are owned as a whole.
+type applyClient struct {
+	client.Client
+
+	// owners maps the fields of each object to their field manager.
+	owners map[client.ObjectKey]map[string]appliedField
+	// appliedBy maps the name of each applied object to the field manager which applied it last.
+	appliedBy map[string]string
+}
+
+type appliedField struct {
+	path    []string
+	value   interface{}
+	manager string
+}
+
+func (c *applyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	if c.owners == nil {
+		c.owners = map[client.ObjectKey]map[string]appliedField{}
+		c.appliedBy = map[string]string{}
+	}
+
+	options := &client.PatchOptions{}
+	options.ApplyOptions(opts)
+	force := options.Force != nil && *options.Force
+
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+	config := map[string]interface{}{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+	metadata, _ := config["metadata"].(map[string]interface{})
+	delete(metadata, "name")
+	delete(metadata, "namespace")
+	delete(metadata, "creationTimestamp")
+	delete(config, "apiVersion")
+	delete(config, "kind")
+
+	fields := map[string]appliedField{}
+	collectFields(config, nil, options.FieldManager, fields)
+
+	key := testutils.ObjectKey(obj)
+	owners := c.owners[key]
+	if owners == nil {
+		owners = map[string]appliedField{}
+		c.owners[key] = owners
+	}
+
+	actual := &unstructured.Unstructured{}
+	actual.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+	created := false
+	if err := c.Client.Get(ctx, key, actual); k8serrors.IsNotFound(err) {
+		created = true
+		actual.SetName(key.Name)
+		actual.SetNamespace(key.Namespace)
+	} else if err != nil {
+		return err
+	}
+
+	causes := []metav1.StatusCause{}
+	for name, field := range fields {
+		owner, ok := owners[name]
+		if ok && owner.manager != field.manager && !reflect.DeepEqual(owner.value, field.value) && !force {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldManagerConflict,
+				Message: fmt.Sprintf("conflict with %q", owner.manager),
+				Field:   "." + name,
+			})
+		}
+	}
+	if len(causes) > 0 {
+		messages := []string{}
+		for _, cause := range causes {
+			messages = append(messages, cause.Message+": "+cause.Field)
+		}
+		sort.Strings(messages)
+		return k8serrors.NewApplyConflict(causes, fmt.Sprintf("Apply failed with %d conflict: %s", len(causes), strings.Join(messages, ", ")))
+	}
+
+	for name, owner := range owners {
+		if _, ok := fields[name]; !ok && owner.manager == options.FieldManager {
+			unstructured.RemoveNestedField(actual.Object, owner.path...)
+			delete(owners, name)
+		}
+	}
+	for name, field := range fields {
+		if err := unstructured.SetNestedField(actual.Object, runtime.DeepCopyJSONValue(field.value), field.path...); err != nil {
+			return err
+		}
+		owners[name] = field
+	}
+	c.appliedBy[key.Name] = options.FieldManager
+
+	if created {
+		err = c.Client.Create(ctx, actual)
+	} else {
+		err = c.Client.Update(ctx, actual)
+	}
+	if err != nil {
+		return err
+	}
+	obj.(*unstructured.Unstructured).Object = actual.Object
+	return nil
+}
+
+func collectFields(value map[string]interface{}, path []string, manager string, fields map[string]appliedField) {
+	for key, v := range value {
+		fieldPath := append(append([]string{}, path...), key)
+		if m, ok := v.(map[string]interface{}); ok {
+			collectFields(m, fieldPath, manager, fields)
+			continue
+		}
+		fields[strings.Join(fieldPath, ".")] = appliedField{path: fieldPath, value: v, manager: manager}
+	}
+}
+
 // Verify that the DeleteExisting method properly cleans up resources during a test step.
 func TestStepDeleteExisting(t *testing.T) {
 	podToDelete := testutils.NewPod("delete-me", testNamespace)
@@ -106,20 +512,24 @@ func TestStepDeleteExisting(t *testing.T) {
 	step := Step{
 		Logger: testutils.NewTestLogger(t, ""),
 		Step: &harness.TestStep{
-			Delete: []harness.ObjectReference{
+			Delete: []harness.Deletion{
 				{
-					ObjectReference: corev1.ObjectReference{
-						Kind:       "Pod",
-						APIVersion: "v1",
-						Name:       "delete-me",
+					ObjectReference: harness.ObjectReference{
+						ObjectReference: corev1.ObjectReference{
+							Kind:       "Pod",
+							APIVersion: "v1",
+							Name:       "delete-me",
+						},
 					},
 				},
 				{
-					ObjectReference: corev1.ObjectReference{
-						Kind:       "Pod",
-						APIVersion: "v1",
-						Name:       "also-delete-me",
-						Namespace:  "default",
+					ObjectReference: harness.ObjectReference{
+						ObjectReference: corev1.ObjectReference{
+							Kind:       "Pod",
+							APIVersion: "v1",
+							Name:       "also-delete-me",
+							Namespace:  "default",
+						},
 					},
 				},
 			},
@@ -173,6 +583,25 @@ func TestCheckResource(t *testing.T) {
 			expected:    testutils.NewPod("hello", ""),
 			shouldError: true,
 		},
+		{
+			testName: "resource expression match",
+			actual: testutils.WithSpec(t, testutils.NewPod("hello", ""), map[string]interface{}{
+				"priority": 10,
+			}),
+			expected: testutils.WithSpec(t, testutils.NewPod("hello", ""), map[string]interface{}{
+				"(priority > `5`)": true,
+			}),
+		},
+		{
+			testName: "resource expression mis-match",
+			actual: testutils.WithSpec(t, testutils.NewPod("hello", ""), map[string]interface{}{
+				"priority": 1,
+			}),
+			expected: testutils.WithSpec(t, testutils.NewPod("hello", ""), map[string]interface{}{
+				"(priority > `5`)": true,
+			}),
+			shouldError: true,
+		},
 	} {
 		test := test
 
@@ -191,7 +620,7 @@ func TestCheckResource(t *testing.T) {
 				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return fakeDiscovery, nil },
 			}
 
-			errors := step.CheckResource(test.expected, namespace, nil)
+			errors := step.CheckResource(test.expected, namespace, nil, nil)
 
 			if test.shouldError {
 				assert.NotEqual(t, []error{}, errors)
@@ -207,6 +636,7 @@ func TestCheckResourceAbsent(t *testing.T) {
 		name        string
 		actual      []runtime.Object
 		expected    runtime.Object
+		options     *harness.Options
 		shouldError bool
 		expectedErr string
 	}{
@@ -237,6 +667,31 @@ func TestCheckResourceAbsent(t *testing.T) {
 			shouldError: true,
 			expectedErr: "resource /v1, Kind=Pod pod1 (and 1 other resources) matched error assertion",
 		},
+		{
+			name: "only selected resources match",
+			actual: []runtime.Object{
+				testutils.WithLabels(t, testutils.NewPod("pod1", ""), map[string]string{"app": "one"}),
+				testutils.WithLabels(t, testutils.NewPod("pod2", ""), map[string]string{"app": "two"}),
+			},
+			expected:    testutils.NewPod("", ""),
+			options:     &harness.Options{LabelSelector: "app in (two,three)"},
+			shouldError: true,
+			expectedErr: "resource /v1, Kind=Pod pod2 matched error assertion",
+		},
+		{
+			name: "no resources selected by labels",
+			actual: []runtime.Object{
+				testutils.WithLabels(t, testutils.NewPod("pod1", ""), map[string]string{"app": "one"}),
+			},
+			expected: testutils.WithLabels(t, testutils.NewPod("", ""), map[string]string{"app": "two"}),
+		},
+		{
+			name:        "invalid selector",
+			actual:      []runtime.Object{testutils.NewPod("pod1", "")},
+			expected:    testutils.NewPod("", ""),
+			options:     &harness.Options{LabelSelector: "app in two"},
+			shouldError: true,
+		},
 		{
 			name:     "resource mis-match",
 			actual:   []runtime.Object{testutils.NewPod("hello", "")},
@@ -266,7 +721,7 @@ func TestCheckResourceAbsent(t *testing.T) {
 				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return fakeDiscovery, nil },
 			}
 
-			error := step.CheckResourceAbsent(test.expected, testNamespace)
+			error := step.CheckResourceAbsent(test.expected, testNamespace, test.options)
 
 			if test.shouldError {
 				assert.Error(t, error)
@@ -280,6 +735,115 @@ func TestCheckResourceAbsent(t *testing.T) {
 	}
 }
 
+func TestCheckResourceCount(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+
+	pods := []runtime.Object{
+		testutils.NewV1Pod("pod1", "", "val1"),
+		testutils.NewV1Pod("pod2", "", "val1"),
+		testutils.NewV1Pod("pod3", "", "val2"),
+	}
+	expected := testutils.WithSpec(t, testutils.NewPod("", ""), map[string]interface{}{"serviceAccountName": "val1"})
+
+	for _, test := range []struct {
+		name        string
+		expected    runtime.Object
+		count       harness.Count
+		expectedErr string
+	}{
+		{
+			name:     "exact count matches",
+			expected: expected,
+			count:    harness.Count{Exact: intPtr(2)},
+		},
+		{
+			name:        "exact count does not match",
+			expected:    expected,
+			count:       harness.Count{Exact: intPtr(3)},
+			expectedErr: "resource Pod:world/: expected exactly 3 matching resources, found 2 matching resources [pod1 pod2]",
+		},
+		{
+			name:     "min and max match",
+			expected: expected,
+			count:    harness.Count{Min: intPtr(1), Max: intPtr(2)},
+		},
+		{
+			name:        "too few resources",
+			expected:    expected,
+			count:       harness.Count{Min: intPtr(3)},
+			expectedErr: "resource Pod:world/: expected at least 3 matching resources, found 2 matching resources [pod1 pod2]",
+		},
+		{
+			name:        "too many resources",
+			expected:    expected,
+			count:       harness.Count{Max: intPtr(1)},
+			expectedErr: "resource Pod:world/: expected at most 1 matching resources, found 2 matching resources [pod1 pod2]",
+		},
+		{
+			name:     "no resources expected",
+			expected: testutils.WithSpec(t, testutils.NewPod("", ""), map[string]interface{}{"serviceAccountName": "val3"}),
+			count:    harness.Count{Exact: intPtr(0)},
+		},
+		{
+			name:     "named resource does not exist",
+			expected: testutils.NewPod("hello", ""),
+			count:    harness.Count{Max: intPtr(0)},
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			fakeDiscovery := testutils.FakeDiscoveryClient()
+
+			for _, object := range pods {
+				_, _, err := testutils.Namespaced(fakeDiscovery, object, testNamespace)
+				assert.NoError(t, err)
+			}
+
+			step := Step{
+				Logger: testutils.NewTestLogger(t, ""),
+				Client: func(bool) (client.Client, error) {
+					return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(pods...).Build(), nil
+				},
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return fakeDiscovery, nil },
+			}
+
+			errors := step.CheckResourceCount(test.expected, testNamespace, nil, &harness.Options{Count: &test.count})
+
+			if test.expectedErr != "" {
+				if assert.Len(t, errors, 1) {
+					assert.EqualError(t, errors[0], test.expectedErr)
+				}
+			} else {
+				assert.Empty(t, errors)
+			}
+		})
+	}
+}
+
+func TestValidateCount(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+
+	assert.NoError(t, validateCount(&harness.Count{Exact: intPtr(1)}))
+	assert.NoError(t, validateCount(&harness.Count{Min: intPtr(1), Max: intPtr(1)}))
+	assert.EqualError(t, validateCount(&harness.Count{Exact: intPtr(1), Max: intPtr(2)}), "exact can not be combined with min or max")
+	assert.EqualError(t, validateCount(&harness.Count{Min: intPtr(2), Max: intPtr(1)}), "min 2 is greater than max 1")
+	assert.EqualError(t, validateCount(&harness.Count{Min: intPtr(-1)}), "count must not be negative")
+}
+
+func TestValidateErrorCount(t *testing.T) {
+	one := 1
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "errors.yaml"), []byte{}, 0600))
+
+	assert.NoError(t, validateTestStep(&harness.TestStep{
+		Error: []harness.Error{{File: "errors.yaml", Options: &harness.Options{}}},
+	}, dir))
+	assert.EqualError(t, validateTestStep(&harness.TestStep{
+		Error: []harness.Error{{File: "errors.yaml", Options: &harness.Options{Count: &harness.Count{Exact: &one}}}},
+	}, dir), "count is not supported for Error errors.yaml, errors files match any number of objects")
+}
+
 func TestRun(t *testing.T) {
 	for _, test := range []struct {
 		testName     string
@@ -364,6 +928,86 @@ func TestRun(t *testing.T) {
 	}
 }
 
+func TestRunHoldFor(t *testing.T) {
+	for _, test := range []struct {
+		testName    string
+		shouldError bool
+		flap        bool
+	}{
+		{testName: "state holds"},
+		{testName: "state flaps", shouldError: true, flap: true},
+	} {
+		test := test
+
+		t.Run(test.testName, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+			step := Step{
+				Apply: []apply{
+					{object: testutils.WithStatus(t, testutils.NewPod("hello", ""), map[string]interface{}{
+						"phase": "Ready",
+					})},
+				},
+				Asserts: []asserts{
+					{object: testutils.WithStatus(t, testutils.NewPod("hello", ""), map[string]interface{}{
+						"phase": "Ready",
+					})},
+				},
+				Assert: &harness.TestAssert{
+					Timeout: 1,
+					HoldFor: &metav1.Duration{Duration: 3 * time.Second},
+				},
+				Client:          func(bool) (client.Client, error) { return cl, nil },
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+				Logger:          testutils.NewTestLogger(t, ""),
+			}
+
+			if test.flap {
+				go func() {
+					time.Sleep(time.Second * 2)
+					pod := testutils.NewPod("hello", testNamespace)
+					assert.Nil(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: "hello"}, pod))
+					assert.Nil(t, cl.Update(context.TODO(), testutils.WithStatus(t, pod, map[string]interface{}{
+						"phase": "Failed",
+					})))
+				}()
+			}
+
+			start := time.Now()
+			errors := step.Run(t, testNamespace)
+
+			if test.shouldError {
+				assert.NotEqual(t, []error{}, errors)
+			} else {
+				assert.Equal(t, []error{}, errors)
+				assert.GreaterOrEqual(t, time.Since(start), 3*time.Second)
+			}
+		})
+	}
+}
+
+func TestStepRunFinally(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "finally")
+
+	step := Step{
+		Logger: testutils.NewTestLogger(t, ""),
+		Step: &harness.TestStep{
+			Finally: []harness.Command{
+				{Script: "echo ${NAME} >> " + output},
+				{Script: "exit 1"},
+				{Script: "echo skipped >> " + output},
+			},
+		},
+		Variables: map[string]string{"NAME": "uninstall"},
+	}
+
+	assert.Error(t, step.RunFinally(testNamespace))
+
+	actual, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "uninstall\n", string(actual))
+}
+
 func TestPopulateObjectsByFileName(t *testing.T) {
 	for _, tt := range []struct {
 		fileName                   string
@@ -549,3 +1193,50 @@ func TestMetaTypeMatches(t *testing.T) {
 		})
 	}
 }
+
+func TestNewSelector(t *testing.T) {
+	for _, test := range []struct {
+		name           string
+		expected       runtime.Object
+		options        *harness.Options
+		expectedLabels string
+		expectedFields string
+		shouldError    bool
+	}{
+		{
+			name:     "no labels",
+			expected: testutils.NewPod("hello", ""),
+		},
+		{
+			name:           "labels of the document",
+			expected:       testutils.WithLabels(t, testutils.NewPod("", ""), map[string]string{"app": "nginx", "(tier != 'frontend')": "true"}),
+			expectedLabels: "app=nginx",
+		},
+		{
+			name:           "labels and selectors",
+			expected:       testutils.WithLabels(t, testutils.NewPod("", ""), map[string]string{"app": "nginx"}),
+			options:        &harness.Options{LabelSelector: "tier!=frontend", FieldSelector: "status.phase=Running"},
+			expectedLabels: "app=nginx,tier!=frontend",
+			expectedFields: "status.phase=Running",
+		},
+		{
+			name:        "invalid field selector",
+			expected:    testutils.NewPod("", ""),
+			options:     &harness.Options{FieldSelector: "status.phase"},
+			shouldError: true,
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			sel, err := newSelector(test.expected, test.options)
+			if test.shouldError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedLabels, sel.labels.String())
+			assert.Equal(t, test.expectedFields, sel.fields.String())
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package test
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	"github.com/kyverno/kuttl/pkg/env"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+// expandFunc expands the variables in a string.
+type expandFunc func(string) (string, error)
+
+// expander returns the function to expand the variables in the objects of the step, or nil if there is nothing to expand.
+// Without templating, only the suite variables and the variables captured by the previous steps are expanded and all
+// other references are left untouched. With templating, $NAMESPACE and OS environment variables are expanded as well.
+func (s *Step) expander(namespace string) expandFunc {
+	if s.Templating == nil || !s.Templating.Enabled {
+		if len(s.Variables) == 0 {
+			return nil
+		}
+		return func(c string) (string, error) {
+			return env.ExpandVariables(c, s.Variables), nil
+		}
+	}
+
+	vars := make(map[string]string, len(s.Variables)+1)
+	for key, value := range s.Variables {
+		vars[key] = value
+	}
+	vars["NAMESPACE"] = namespace
+
+	if s.Templating.Strict {
+		return func(c string) (string, error) {
+			return env.ExpandWithMapStrict(c, vars)
+		}
+	}
+	return func(c string) (string, error) {
+		return env.ExpandWithMap(c, vars), nil
+	}
+}
+
+// expandVariables expands the variables in the objects to apply, patch, replace, dry-run, assert, error, delete and
+// capture, and in the access checks.
+func (s *Step) expandVariables(namespace string) error {
+	expand := s.expander(namespace)
+	if expand == nil {
+		return nil
+	}
+
+	for i := range s.Apply {
+		obj, err := expandObject(s.Apply[i].object, expand)
+		if err != nil {
+			return err
+		}
+		s.Apply[i].object = obj
+	}
+
+	for i := range s.Replaces {
+		obj, err := expandObject(s.Replaces[i].object, expand)
+		if err != nil {
+			return err
+		}
+		s.Replaces[i].object = obj
+	}
+
+	for i := range s.DryRuns {
+		for j := range s.DryRuns[i].objects {
+			obj, err := expandObject(s.DryRuns[i].objects[j], expand)
+			if err != nil {
+				return err
+			}
+			s.DryRuns[i].objects[j] = obj
+		}
+		for j := range s.DryRuns[i].expected {
+			obj, err := expandObject(s.DryRuns[i].expected[j], expand)
+			if err != nil {
+				return err
+			}
+			s.DryRuns[i].expected[j] = obj
+		}
+	}
+
+	for i := range s.Patches {
+		if err := expandReference(&s.Patches[i].ref, expand); err != nil {
+			return err
+		}
+		body, err := expandValue(s.Patches[i].body, expand)
+		if err != nil {
+			return fmt.Errorf("expanding variables in patch of %s: %w", s.Patches[i].ref.Name, err)
+		}
+		s.Patches[i].body = body
+	}
+
+	for i := range s.Asserts {
+		obj, err := expandObject(s.Asserts[i].object, expand)
+		if err != nil {
+			return err
+		}
+		s.Asserts[i].object = obj
+	}
+
+	for i := range s.Errors {
+		obj, err := expandObject(s.Errors[i].object, expand)
+		if err != nil {
+			return err
+		}
+		s.Errors[i].object = obj
+	}
+
+	if s.Assert != nil {
+		for i := range s.Assert.Absent {
+			if err := expandReference(&s.Assert.Absent[i], expand); err != nil {
+				return err
+			}
+		}
+		for i := range s.Assert.Ownership {
+			check := &s.Assert.Ownership[i]
+			if err := expandReference(&check.Owner, expand); err != nil {
+				return err
+			}
+			for j := range check.Dependents {
+				if err := expandReference(&check.Dependents[j], expand); err != nil {
+					return err
+				}
+			}
+		}
+		if err := expandCaptures(s.Assert.Capture, expand); err != nil {
+			return err
+		}
+		for i := range s.Assert.Access {
+			if err := expandAccessCheck(&s.Assert.Access[i], expand); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.Step != nil {
+		for i := range s.Step.Delete {
+			deletion := &s.Step.Delete[i]
+			if err := expandReference(&deletion.ObjectReference, expand); err != nil {
+				return err
+			}
+			var err error
+			if deletion.LabelSelector, err = expand(deletion.LabelSelector); err != nil {
+				return fmt.Errorf("expanding variables in label selector %q: %w", deletion.LabelSelector, err)
+			}
+			if deletion.FieldSelector, err = expand(deletion.FieldSelector); err != nil {
+				return fmt.Errorf("expanding variables in field selector %q: %w", deletion.FieldSelector, err)
+			}
+		}
+		if err := expandCaptures(s.Step.Capture, expand); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expandObject returns a copy of obj with the variables expanded in all string values.
+func expandObject(obj client.Object, expand expandFunc) (client.Object, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("expanding variables in %s: %w", testutils.ResourceID(obj), err)
+	}
+
+	expanded, err := expandValue(content, expand)
+	if err != nil {
+		return nil, fmt.Errorf("expanding variables in %s: %w", testutils.ResourceID(obj), err)
+	}
+
+	// keep the type of obj, typed objects are decoded again
+	if _, ok := obj.(*unstructured.Unstructured); ok {
+		return &unstructured.Unstructured{Object: expanded.(map[string]interface{})}, nil
+	}
+
+	out, ok := obj.DeepCopyObject().(client.Object)
+	if !ok {
+		return nil, fmt.Errorf("expanding variables in %s: unexpected type %T", testutils.ResourceID(obj), obj)
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(expanded.(map[string]interface{}), out); err != nil {
+		return nil, fmt.Errorf("expanding variables in %s: %w", testutils.ResourceID(obj), err)
+	}
+	return out, nil
+}
+
+// expandValue expands the variables in all strings in value.
+func expandValue(value interface{}, expand expandFunc) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return expand(v)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			expanded, err := expandValue(val, expand)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = expanded
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			expanded, err := expandValue(val, expand)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = expanded
+		}
+		return out, nil
+	}
+	return value, nil
+}
+
+// expandReference expands the variables in the name, namespace and labels of ref.
+func expandReference(ref *harness.ObjectReference, expand expandFunc) error {
+	var err error
+	if ref.Name, err = expand(ref.Name); err != nil {
+		return fmt.Errorf("expanding variables in reference %s: %w", ref.Name, err)
+	}
+	if ref.Namespace, err = expand(ref.Namespace); err != nil {
+		return fmt.Errorf("expanding variables in reference %s: %w", ref.Name, err)
+	}
+	for key, value := range ref.Labels {
+		if ref.Labels[key], err = expand(value); err != nil {
+			return fmt.Errorf("expanding variables in reference %s: %w", ref.Name, err)
+		}
+	}
+	return nil
+}
+
+// expandCaptures expands the variables in the object references of captures.
+func expandCaptures(captures []harness.Capture, expand expandFunc) error {
+	for i := range captures {
+		if err := expandReference(&captures[i].Object, expand); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandAccessCheck expands the variables in the name, namespace and subject of check.
+func expandAccessCheck(check *harness.AccessCheck, expand expandFunc) error {
+	var err error
+	if check.Name, err = expand(check.Name); err != nil {
+		return fmt.Errorf("expanding variables in access check of %s: %w", check.Resource, err)
+	}
+	if check.Namespace, err = expand(check.Namespace); err != nil {
+		return fmt.Errorf("expanding variables in access check of %s: %w", check.Resource, err)
+	}
+	if check.Subject == nil {
+		return nil
+	}
+	if check.Subject.User, err = expand(check.Subject.User); err != nil {
+		return fmt.Errorf("expanding variables in access check of %s: %w", check.Resource, err)
+	}
+	if check.Subject.ServiceAccount, err = expand(check.Subject.ServiceAccount); err != nil {
+		return fmt.Errorf("expanding variables in access check of %s: %w", check.Resource, err)
+	}
+	for i, group := range check.Subject.Groups {
+		if check.Subject.Groups[i], err = expand(group); err != nil {
+			return fmt.Errorf("expanding variables in access check of %s: %w", check.Resource, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+func TestExpandObject(t *testing.T) {
+	step := Step{Variables: map[string]string{"POD_NAME": "hello-x7k2p"}}
+	expand := step.expander(testNamespace)
+
+	pod := testutils.WithSpec(t, testutils.NewPod("${POD_NAME}-copy", ""), map[string]interface{}{
+		"containers": []interface{}{
+			map[string]interface{}{
+				"name":    "nginx",
+				"image":   "nginx:1.7.9",
+				"command": []interface{}{"sh", "-c", "echo $POD_NAME $HOME $$"},
+			},
+		},
+	})
+
+	expanded, err := expandObject(pod, expand)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello-x7k2p-copy", expanded.GetName())
+
+	containers, _, _ := unstructured.NestedSlice(expanded.(*unstructured.Unstructured).Object, "spec", "containers")
+	assert.Equal(t, []interface{}{"sh", "-c", "echo hello-x7k2p $HOME $$"}, containers[0].(map[string]interface{})["command"])
+
+	// typed objects keep their type
+	typed := &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: "config"},
+		Data:       map[string]string{"pod": "$POD_NAME"},
+	}
+
+	expanded, err = expandObject(typed, expand)
+	assert.NoError(t, err)
+	if assert.IsType(t, &corev1.ConfigMap{}, expanded) {
+		assert.Equal(t, map[string]string{"pod": "hello-x7k2p"}, expanded.(*corev1.ConfigMap).Data)
+	}
+	assert.Equal(t, "$POD_NAME", typed.Data["pod"])
+}
+
+func TestExpandVariablesTemplating(t *testing.T) {
+	os.Setenv("KUTTL_TEST_123", "hello")
+	t.Cleanup(func() {
+		os.Unsetenv("KUTTL_TEST_123")
+	})
+
+	newBinding := func(subjectNamespace string) *unstructured.Unstructured {
+		binding := testutils.NewResource("rbac.authorization.k8s.io/v1", "RoleBinding", "binding", "")
+		binding.Object["subjects"] = []interface{}{
+			map[string]interface{}{"kind": "ServiceAccount", "name": "default", "namespace": subjectNamespace},
+		}
+		return binding
+	}
+
+	for _, test := range []struct {
+		name        string
+		templating  *harness.Templating
+		variables   map[string]string
+		in          string
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:     "no templating",
+			in:       "$NAMESPACE",
+			expected: "$NAMESPACE",
+		},
+		{
+			name:      "no templating expands the variables only",
+			variables: map[string]string{"SUFFIX": "a"},
+			in:        "$NAMESPACE-$KUTTL_TEST_123-$SUFFIX",
+			expected:  "$NAMESPACE-$KUTTL_TEST_123-a",
+		},
+		{
+			name:       "templating",
+			templating: &harness.Templating{Enabled: true},
+			variables:  map[string]string{"SUFFIX": "a"},
+			in:         "$NAMESPACE-$KUTTL_TEST_123-${SUFFIX}-$$-$UNDEFINED_1234",
+			expected:   testNamespace + "-hello-a-$-",
+		},
+		{
+			name:        "strict templating",
+			templating:  &harness.Templating{Enabled: true, Strict: true},
+			in:          "$NAMESPACE-$UNDEFINED_1234",
+			expectedErr: "expanding variables in RoleBinding:/binding: undefined variables: UNDEFINED_1234",
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			step := Step{
+				Templating: test.templating,
+				Variables:  test.variables,
+				Apply:      []apply{{object: newBinding(test.in)}},
+				Asserts:    []asserts{{object: newBinding(test.in)}},
+			}
+
+			err := step.expandVariables(testNamespace)
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+
+			for _, obj := range []interface{}{step.Apply[0].object, step.Asserts[0].object} {
+				subjects, _, _ := unstructured.NestedSlice(obj.(*unstructured.Unstructured).Object, "subjects")
+				assert.Equal(t, test.expected, subjects[0].(map[string]interface{})["namespace"])
+			}
+		})
+	}
+}
+
+func TestExpandVariablesReferences(t *testing.T) {
+	step := Step{
+		Templating: &harness.Templating{Enabled: true},
+		Variables:  map[string]string{"NAME": "hello"},
+		Step: &harness.TestStep{
+			Capture: []harness.Capture{
+				{Variable: "IP", Object: podReference("$NAME", map[string]string{"app": "$NAME"})},
+			},
+		},
+		Assert: &harness.TestAssert{
+			Capture: []harness.Capture{
+				{Variable: "UID", Object: namespacedPodReference("${NAME}-pod", "$NAMESPACE")},
+			},
+			Access: []harness.AccessCheck{
+				{
+					Subject:   &harness.Impersonation{ServiceAccount: "$NAMESPACE/$NAME", Groups: []string{"$NAME-group"}},
+					Verb:      "get",
+					Resource:  "pods",
+					Name:      "$NAME",
+					Namespace: "$NAMESPACE",
+				},
+			},
+		},
+	}
+
+	assert.NoError(t, step.expandVariables(testNamespace))
+
+	assert.Equal(t, podReference("hello", map[string]string{"app": "hello"}), step.Step.Capture[0].Object)
+	assert.Equal(t, namespacedPodReference("hello-pod", testNamespace), step.Assert.Capture[0].Object)
+	assert.Equal(t, harness.AccessCheck{
+		Subject:   &harness.Impersonation{ServiceAccount: testNamespace + "/hello", Groups: []string{"hello-group"}},
+		Verb:      "get",
+		Resource:  "pods",
+		Name:      "hello",
+		Namespace: testNamespace,
+	}, step.Assert.Access[0])
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jmespath/go-jmespath"
+)
+
+// IsExpression returns true if key is an expression key, i.e. a JMESPath expression wrapped in parentheses
+// such as `(spec.replicas >= `2`)`.
+func IsExpression(key string) bool {
+	return len(key) > 2 && strings.HasPrefix(key, "(") && strings.HasSuffix(key, ")")
+}
+
+// EvaluateExpression evaluates the JMESPath expression wrapped in key against data.
+// Numbers are normalized to float64 before evaluation so that comparisons in the expression work
+// regardless of how the object was decoded.
+func EvaluateExpression(key string, data interface{}) (interface{}, error) {
+	expression := strings.TrimSpace(key[1 : len(key)-1])
+
+	normalized, err := normalizeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := jmespath.Search(expression, normalized)
+	if err != nil {
+		return nil, fmt.Errorf("evaluating expression %q: %w", expression, err)
+	}
+	return result, nil
+}
+
+// normalizeJSON round trips v through JSON so that it only contains the types produced by encoding/json.
+func normalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
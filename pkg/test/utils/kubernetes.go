@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -38,6 +39,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/serializer/json"
 	"k8s.io/apimachinery/pkg/types"
 	apijson "k8s.io/apimachinery/pkg/util/json"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/apimachinery/pkg/watch"
@@ -47,11 +49,9 @@ import (
 	"k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth" // package needed for auth providers like GCP
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/restmapper"
 	coretesting "k8s.io/client-go/testing"
 	api "k8s.io/client-go/tools/clientcmd/api/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 
 	"github.com/kyverno/kuttl/pkg/apis"
@@ -156,9 +156,53 @@ func Retry(ctx context.Context, fn func(context.Context) error, errValidationFun
 
 // RetryClient implements the Client interface, with retries built in.
 type RetryClient struct {
-	Client    client.Client
-	dynamic   dynamic.Interface
-	discovery discovery.DiscoveryInterface
+	Client  client.Client
+	dynamic dynamic.Interface
+
+	// capturing is true if the transport of the client records warnings, see CaptureWarnings.
+	capturing bool
+}
+
+// WarningCapturer is implemented by clients which can capture the warnings returned by the API server.
+type WarningCapturer interface {
+	// CaptureWarnings calls fn and returns the warnings returned by the API server to the requests made with the
+	// client and the context passed to fn.
+	CaptureWarnings(ctx context.Context, fn func(ctx context.Context) error) ([]string, error)
+}
+
+// warningRecorderKey is the context key of the warningRecorder of a request.
+type warningRecorderKey struct{}
+
+// warningRecorder records the warnings returned by the API server to the requests made with a context holding it.
+type warningRecorder struct {
+	lock     sync.Mutex
+	warnings []string
+}
+
+// warningTransport records the persistent warnings of the responses in the warningRecorder of the context of the
+// request, if any. The warnings are still passed to the warning handler of the client.
+type warningTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *warningTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	recorder, ok := req.Context().Value(warningRecorderKey{}).(*warningRecorder)
+	if err != nil || !ok {
+		return resp, err
+	}
+
+	warnings, _ := utilnet.ParseWarningHeaders(resp.Header.Values("Warning"))
+
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	for _, warning := range warnings {
+		if warning.Code == 299 && len(warning.Text) > 0 {
+			recorder.warnings = append(recorder.warnings, warning.Text)
+		}
+	}
+	return resp, nil
 }
 
 // RetryStatusWriter implements the StatusWriter interface, with retries built in.
@@ -168,25 +212,58 @@ type RetryStatusWriter struct {
 
 // NewRetryClient initializes a new Kubernetes client that automatically retries on network-related errors.
 func NewRetryClient(cfg *rest.Config, opts client.Options) (*RetryClient, error) {
-	dynamicClient, err := dynamic.NewForConfig(cfg)
-	if err != nil {
-		return nil, err
-	}
+	cfg = rest.CopyConfig(cfg)
+	cfg.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &warningTransport{next: rt}
+	})
 
-	discovery, err := discovery.NewDiscoveryClientForConfig(cfg)
+	dynamicClient, err := dynamic.NewForConfig(cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	if opts.Mapper == nil {
-		opts.Mapper, err = apiutil.NewDynamicRESTMapper(cfg)
+		dClient, err := NewCachedDiscoveryClient(cfg)
 		if err != nil {
 			return nil, err
 		}
+		opts.Mapper = NewRESTMapper(dClient)
+	}
+
+	client, err := client.NewWithWatch(cfg, opts)
+	return &RetryClient{Client: client, dynamic: dynamicClient, capturing: true}, err
+}
+
+// RefreshMapping discards the cached REST mapping of the client if its REST mapper can be reset, so that kinds
+// served since it was loaded are mapped.
+func (r *RetryClient) RefreshMapping() {
+	if mapper, ok := r.RESTMapper().(meta.ResettableRESTMapper); ok {
+		mapper.Reset()
+	}
+}
+
+// CaptureWarnings calls fn and returns the warnings returned by the API server to the requests made with r and the
+// context passed to fn. The warnings of concurrent requests made with other contexts are not captured.
+func (r *RetryClient) CaptureWarnings(ctx context.Context, fn func(ctx context.Context) error) ([]string, error) {
+	if !r.capturing {
+		return nil, fn(ctx)
 	}
 
-	client, err := client.New(cfg, opts)
-	return &RetryClient{Client: client, dynamic: dynamicClient, discovery: discovery}, err
+	recorder := &warningRecorder{}
+	err := fn(context.WithValue(ctx, warningRecorderKey{}, recorder))
+
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	return recorder.warnings, err
+}
+
+// CaptureWarnings calls fn and returns the warnings returned by the API server to the requests made with cl and the
+// context passed to fn, if cl is a WarningCapturer. Otherwise fn is called with ctx and no warnings are returned.
+func CaptureWarnings(ctx context.Context, cl client.Client, fn func(ctx context.Context) error) ([]string, error) {
+	if capturer, ok := cl.(WarningCapturer); ok {
+		return capturer.CaptureWarnings(ctx, fn)
+	}
+	return nil, fn(ctx)
 }
 
 // Scheme returns the scheme this client is using.
@@ -256,29 +333,51 @@ func (r *RetryClient) List(ctx context.Context, list client.ObjectList, opts ...
 
 // Watch watches a specific object and returns all events for it.
 func (r *RetryClient) Watch(ctx context.Context, obj runtime.Object) (watch.Interface, error) {
-	meta, err := meta.Accessor(obj)
+	m, err := meta.Accessor(obj)
 	if err != nil {
 		return nil, err
 	}
 
 	gvk := obj.GetObjectKind().GroupVersionKind()
 
-	groupResources, err := restmapper.GetAPIGroupResources(r.discovery)
-	if err != nil {
-		return nil, err
-	}
-
-	mapping, err := restmapper.NewDiscoveryRESTMapper(groupResources).RESTMapping(gvk.GroupKind(), gvk.Version)
-	if err != nil {
+	var mapping *meta.RESTMapping
+	if err := WithMappingRefresh(r, func() (err error) {
+		mapping, err = r.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+		return err
+	}); err != nil {
 		return nil, err
 	}
 
 	return r.dynamic.Resource(mapping.Resource).Watch(context.TODO(), metav1.SingleObject(metav1.ObjectMeta{
-		Name:      meta.GetName(),
-		Namespace: meta.GetNamespace(),
+		Name:      m.GetName(),
+		Namespace: m.GetNamespace(),
 	}))
 }
 
+// WatchList watches all objects of the kind of list which match the list options and returns all events for them.
+func (r *RetryClient) WatchList(ctx context.Context, list client.ObjectList, opts ...client.ListOption) (watch.Interface, error) {
+	watcher, ok := r.Client.(client.WithWatch)
+	if !ok {
+		return nil, ErrWatchNotSupported
+	}
+	return watcher.Watch(ctx, list, opts...)
+}
+
+// ErrWatchNotSupported is returned by WatchList if the client does not support watching lists of objects.
+var ErrWatchNotSupported = errors.New("client does not support watching objects")
+
+// WatchList watches all objects of the kind of list which match the list options with the given client.
+// The client must either be a RetryClient or implement client.WithWatch, ErrWatchNotSupported is returned otherwise.
+func WatchList(ctx context.Context, cl client.Client, list client.ObjectList, opts ...client.ListOption) (watch.Interface, error) {
+	switch c := cl.(type) {
+	case *RetryClient:
+		return c.WatchList(ctx, list, opts...)
+	case client.WithWatch:
+		return c.Watch(ctx, list, opts...)
+	}
+	return nil, ErrWatchNotSupported
+}
+
 // Status returns a client which can update status subresource for kubernetes objects.
 func (r *RetryClient) Status() client.StatusWriter {
 	return &RetryStatusWriter{
@@ -348,6 +447,11 @@ func Namespaced(dClient discovery.DiscoveryInterface, obj runtime.Object, namesp
 	}
 
 	resource, err := GetAPIResource(dClient, obj.GetObjectKind().GroupVersionKind())
+	if cached, ok := dClient.(discovery.CachedDiscoveryInterface); ok && isStaleDiscoveryError(err) {
+		// the kind may have been served since the discovery information was cached
+		cached.Invalidate()
+		resource, err = GetAPIResource(dClient, obj.GetObjectKind().GroupVersionKind())
+	}
 	if err != nil {
 		return "", "", fmt.Errorf("retrieving API resource for %v failed: %v", obj.GetObjectKind().GroupVersionKind(), err)
 	}
@@ -360,7 +464,8 @@ func Namespaced(dClient discovery.DiscoveryInterface, obj runtime.Object, namesp
 	return m.GetName(), namespace, nil
 }
 
-// PrettyDiff creates a unified diff highlighting the differences between two Kubernetes resources
+// PrettyDiff creates a unified diff highlighting the differences between two Kubernetes resources.
+// Fields of actual which are not defined in expected are left out of the diff.
 func PrettyDiff(expected runtime.Object, actual runtime.Object) (string, error) {
 	expectedBuf := &bytes.Buffer{}
 	actualBuf := &bytes.Buffer{}
@@ -369,7 +474,22 @@ func PrettyDiff(expected runtime.Object, actual runtime.Object) (string, error)
 		return "", err
 	}
 
-	if err := MarshalObject(actual, actualBuf); err != nil {
+	expectedObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(expected)
+	if err != nil {
+		return "", err
+	}
+
+	actualObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(actual)
+	if err != nil {
+		return "", err
+	}
+
+	pruned, ok := pruneToSubset(expectedObj, actualObj).(map[string]interface{})
+	if !ok {
+		pruned = actualObj
+	}
+
+	if err := MarshalObject(&unstructured.Unstructured{Object: pruned}, actualBuf); err != nil {
 		return "", err
 	}
 
@@ -843,7 +963,9 @@ func CreateOrUpdate(ctx context.Context, cl client.Client, obj client.Object, re
 		actual := &unstructured.Unstructured{}
 		actual.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
 
-		err := cl.Get(ctx, ObjectKey(expected), actual)
+		err := WithMappingRefresh(cl, func() error {
+			return cl.Get(ctx, ObjectKey(expected), actual)
+		})
 		if err == nil {
 			if err = PatchObject(actual, expected); err != nil {
 				return err
@@ -858,7 +980,9 @@ func CreateOrUpdate(ctx context.Context, cl client.Client, obj client.Object, re
 			err = cl.Patch(ctx, actual, client.RawPatch(types.MergePatchType, expectedBytes))
 			updated = true
 		} else if k8serrors.IsNotFound(err) {
-			err = cl.Create(ctx, obj)
+			err = WithMappingRefresh(cl, func() error {
+				return cl.Create(ctx, obj)
+			})
 			updated = false
 		}
 		return err
@@ -869,6 +993,88 @@ func CreateOrUpdate(ctx context.Context, cl client.Client, obj client.Object, re
 	return updated, err
 }
 
+// DryRunCreateOrUpdate submits obj like CreateOrUpdate with server-side dry-run, so that it is admitted but not
+// persisted. It returns the object returned by the API server, e.g. once mutated by admission webhooks.
+func DryRunCreateOrUpdate(ctx context.Context, cl client.Client, obj client.Object) (result *unstructured.Unstructured, updated bool, err error) {
+	obj = obj.DeepCopyObject().(client.Object)
+
+	actual := &unstructured.Unstructured{}
+	actual.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+
+	err = WithMappingRefresh(cl, func() error {
+		return cl.Get(ctx, ObjectKey(obj), actual)
+	})
+	if err == nil {
+		if err = PatchObject(actual, obj); err != nil {
+			return nil, false, err
+		}
+
+		var expectedBytes []byte
+		if expectedBytes, err = apijson.Marshal(obj); err != nil {
+			return nil, false, err
+		}
+
+		err = cl.Patch(ctx, actual, client.RawPatch(types.MergePatchType, expectedBytes), client.DryRunAll)
+		return actual, true, err
+	} else if !k8serrors.IsNotFound(err) {
+		return nil, false, err
+	}
+
+	if err = WithMappingRefresh(cl, func() error {
+		return cl.Create(ctx, obj, client.DryRunAll)
+	}); err != nil {
+		return nil, false, err
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, false, err
+	}
+	result = &unstructured.Unstructured{Object: content}
+	result.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+	return result, false, nil
+}
+
+// DefaultFieldManager is the field manager used to server-side apply objects if none is configured.
+const DefaultFieldManager = "kuttl"
+
+// ServerSideApply applies obj with server-side apply as fieldManager, taking the ownership of conflicting fields if force
+// is set. Conflicts with other field managers are not retried.
+func ServerSideApply(ctx context.Context, cl client.Client, obj client.Object, fieldManager string, force bool) (updated bool, err error) {
+	if fieldManager == "" {
+		fieldManager = DefaultFieldManager
+	}
+
+	opts := []client.PatchOption{client.FieldOwner(fieldManager)}
+	if force {
+		opts = append(opts, client.ForceOwnership)
+	}
+
+	actual := &unstructured.Unstructured{}
+	actual.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+
+	err = WithMappingRefresh(cl, func() error {
+		return cl.Get(ctx, ObjectKey(obj), actual)
+	})
+	if err == nil {
+		updated = true
+	} else if !k8serrors.IsNotFound(err) {
+		return false, err
+	}
+
+	// managed fields and resource version can not be set in an apply configuration
+	obj.SetManagedFields(nil)
+	obj.SetResourceVersion("")
+
+	err = WithMappingRefresh(cl, func() error {
+		return cl.Patch(ctx, obj, client.Apply, opts...)
+	})
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = errors.New("server-side apply timeout exceeded")
+	}
+	return updated, err
+}
+
 // SetAnnotation sets the given key and value in the object's annotations, returning a copy.
 func SetAnnotation(obj *unstructured.Unstructured, key, value string) *unstructured.Unstructured {
 	obj = obj.DeepCopy()
@@ -898,7 +1104,7 @@ func GetAPIResource(dClient discovery.DiscoveryInterface, gvk schema.GroupVersio
 		return resource, nil
 	}
 
-	return metav1.APIResource{}, errors.New("resource type not found")
+	return metav1.APIResource{}, ErrResourceTypeNotFound
 }
 
 // WaitForDelete waits for the provide runtime objects to be deleted from cluster
@@ -1034,7 +1240,8 @@ func GetArgs(ctx context.Context, cmd harness.Command, namespace string, envMap
 // RunCommand runs a command with args.
 // args gets split on spaces (respecting quoted strings).
 // if the command is run in the background a reference to the process is returned for later cleanup
-func RunCommand(ctx context.Context, namespace string, cmd harness.Command, cwd string, stdout io.Writer, stderr io.Writer, logger Logger, timeout int, kubeconfigOverride string) (*exec.Cmd, error) {
+// variables are added to the environment of the command, they do not override $NAMESPACE, $KUBECONFIG and $PATH.
+func RunCommand(ctx context.Context, namespace string, cmd harness.Command, cwd string, stdout io.Writer, stderr io.Writer, logger Logger, timeout int, kubeconfigOverride string, variables map[string]string) (*exec.Cmd, error) {
 	actualDir, err := os.Getwd()
 	if err != nil {
 		return nil, fmt.Errorf("command %q with %w", cmd.Command, err)
@@ -1044,6 +1251,9 @@ func RunCommand(ctx context.Context, namespace string, cmd harness.Command, cwd
 		return nil, errors.New("background commands cannot have an output validation")
 	}
 	kuttlENV := make(map[string]string)
+	for key, value := range variables {
+		kuttlENV[key] = value
+	}
 	kuttlENV["NAMESPACE"] = namespace
 	kuttlENV["KUBECONFIG"] = kubeconfigPath(actualDir, kubeconfigOverride)
 	kuttlENV["PATH"] = fmt.Sprintf("%s/bin/:%s", actualDir, os.Getenv("PATH"))
@@ -1153,14 +1363,14 @@ func convertAssertCommand(assertCommands []harness.TestAssertCommand, timeout in
 }
 
 // RunAssertCommands runs a set of commands specified as TestAssertCommand
-func RunAssertCommands(ctx context.Context, logger Logger, namespace string, commands []harness.TestAssertCommand, workdir string, timeout int, kubeconfigOverride string) ([]*exec.Cmd, error) {
-	return RunCommands(ctx, logger, namespace, convertAssertCommand(commands, timeout), workdir, timeout, kubeconfigOverride)
+func RunAssertCommands(ctx context.Context, logger Logger, namespace string, commands []harness.TestAssertCommand, workdir string, timeout int, kubeconfigOverride string, variables map[string]string) ([]*exec.Cmd, error) {
+	return RunCommands(ctx, logger, namespace, convertAssertCommand(commands, timeout), workdir, timeout, kubeconfigOverride, variables)
 }
 
 // RunCommands runs a set of commands, returning any errors.
 // If any (non-background) command fails, the following commands are skipped
 // commands running in the background are returned
-func RunCommands(ctx context.Context, logger Logger, namespace string, commands []harness.Command, workdir string, timeout int, kubeconfigOverride string) ([]*exec.Cmd, error) {
+func RunCommands(ctx context.Context, logger Logger, namespace string, commands []harness.Command, workdir string, timeout int, kubeconfigOverride string, variables map[string]string) ([]*exec.Cmd, error) {
 	bgs := []*exec.Cmd{}
 
 	if commands == nil {
@@ -1168,7 +1378,7 @@ func RunCommands(ctx context.Context, logger Logger, namespace string, commands
 	}
 
 	for i, cmd := range commands {
-		bg, err := RunCommand(ctx, namespace, cmd, workdir, logger, logger, logger, timeout, kubeconfigOverride)
+		bg, err := RunCommand(ctx, namespace, cmd, workdir, logger, logger, logger, timeout, kubeconfigOverride, variables)
 		if err != nil {
 			cmdListSize := len(commands)
 			if i+1 < cmdListSize {
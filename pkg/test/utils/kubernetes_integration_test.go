@@ -121,7 +121,7 @@ func TestRunCommand(t *testing.T) {
 
 	logger := NewTestLogger(t, "")
 	// assert foreground cmd returns nil
-	cmd, err := RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 0, "")
+	cmd, err := RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 0, "", nil)
 	assert.NoError(t, err)
 	assert.Nil(t, cmd)
 	// foreground processes should have stdout
@@ -131,7 +131,7 @@ func TestRunCommand(t *testing.T) {
 	stdout = &bytes.Buffer{}
 
 	// assert background cmd returns process
-	cmd, err = RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 0, "")
+	cmd, err = RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 0, "", nil)
 	assert.NoError(t, err)
 	assert.NotNil(t, cmd)
 	// no stdout for background processes
@@ -142,7 +142,7 @@ func TestRunCommand(t *testing.T) {
 	hcmd.Command = "sleep 42"
 
 	// assert foreground cmd times out
-	cmd, err = RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 2, "")
+	cmd, err = RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 2, "", nil)
 	assert.Error(t, err)
 	assert.True(t, strings.Contains(err.Error(), "timeout"))
 	assert.Nil(t, cmd)
@@ -153,7 +153,7 @@ func TestRunCommand(t *testing.T) {
 	hcmd.Timeout = 2
 
 	// assert foreground cmd times out with command timeout
-	cmd, err = RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 0, "")
+	cmd, err = RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 0, "", nil)
 	assert.Error(t, err)
 	assert.True(t, strings.Contains(err.Error(), "timeout"))
 	assert.Nil(t, cmd)
@@ -169,12 +169,12 @@ func TestRunCommandIgnoreErrors(t *testing.T) {
 
 	logger := NewTestLogger(t, "")
 	// assert foreground cmd returns nil
-	cmd, err := RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 0, "")
+	cmd, err := RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 0, "", nil)
 	assert.NoError(t, err)
 	assert.Nil(t, cmd)
 
 	hcmd.IgnoreFailure = false
-	cmd, err = RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 0, "")
+	cmd, err = RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 0, "", nil)
 	assert.Error(t, err)
 	assert.Nil(t, cmd)
 
@@ -183,7 +183,7 @@ func TestRunCommandIgnoreErrors(t *testing.T) {
 		Command:       "bad-command",
 		IgnoreFailure: true,
 	}
-	cmd, err = RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 0, "")
+	cmd, err = RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 0, "", nil)
 	assert.Error(t, err)
 	assert.Nil(t, cmd)
 }
@@ -197,7 +197,7 @@ func TestRunCommandSkipLogOutput(t *testing.T) {
 
 	logger := NewTestLogger(t, "")
 	// test there is a stdout
-	cmd, err := RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 0, "")
+	cmd, err := RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 0, "", nil)
 	assert.NoError(t, err)
 	assert.Nil(t, cmd)
 	assert.True(t, stdout.Len() > 0)
@@ -206,7 +206,7 @@ func TestRunCommandSkipLogOutput(t *testing.T) {
 	stdout = &bytes.Buffer{}
 	stderr = &bytes.Buffer{}
 	// test there is no stdout
-	cmd, err = RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 0, "")
+	cmd, err = RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 0, "", nil)
 	assert.NoError(t, err)
 	assert.Nil(t, cmd)
 	assert.True(t, stdout.Len() == 0)
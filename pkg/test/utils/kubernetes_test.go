@@ -4,7 +4,11 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path"
 	"testing"
 	"time"
 
@@ -15,6 +19,7 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
 
 	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
 )
@@ -287,6 +292,51 @@ metadata:
 	assert.False(t, MatchesKind(objs[1], svc, pod))
 }
 
+func TestPrettyDiff(t *testing.T) {
+	expected := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      "hello",
+			"namespace": "world",
+		},
+		"status": map[string]interface{}{
+			"readyReplicas": int64(2),
+		},
+	}}
+	actual := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      "hello",
+			"namespace": "world",
+			"labels": map[string]interface{}{
+				"app": "hello",
+			},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+		},
+		"status": map[string]interface{}{
+			"readyReplicas": int64(1),
+			"replicas":      int64(2),
+		},
+	}}
+
+	diff, err := PrettyDiff(expected, actual)
+	assert.NoError(t, err)
+	assert.Equal(t, `--- Deployment:world/hello
++++ Deployment:world/hello
+@@ -4,5 +4,5 @@
+   name: hello
+   namespace: world
+ status:
+-  readyReplicas: 2
++  readyReplicas: 1
+ 
+`, diff)
+}
+
 func TestGetKubectlArgs(t *testing.T) {
 	for _, test := range []struct {
 		testName  string
@@ -500,7 +550,7 @@ func TestRunScript(t *testing.T) {
 
 			logger := NewTestLogger(t, "")
 			// script runs with output
-			_, err := RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 0, "")
+			_, err := RunCommand(context.TODO(), "", hcmd, "", stdout, stderr, logger, 0, "", nil)
 
 			if tt.wantedErr {
 				assert.Error(t, err)
@@ -515,3 +565,37 @@ func TestRunScript(t *testing.T) {
 		})
 	}
 }
+
+func TestCaptureWarnings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Base(r.URL.Path)
+		w.Header().Add("Warning", fmt.Sprintf(`299 - "warning for %s"`, name))
+		w.Header().Add("Warning", `199 - "miscellaneous warning"`)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": %q, "namespace": "world"}}`, name)
+	}))
+	defer server.Close()
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, meta.RESTScopeNamespace)
+
+	cl, err := NewRetryClient(&rest.Config{Host: server.URL, WarningHandler: rest.NoWarnings{}}, client.Options{Mapper: mapper})
+	assert.NoError(t, err)
+
+	get := func(ctx context.Context, name string) error {
+		return cl.Get(ctx, client.ObjectKey{Namespace: "world", Name: name}, NewPod(name, "world"))
+	}
+
+	warnings, err := CaptureWarnings(context.TODO(), cl, func(ctx context.Context) error {
+		// the warnings of requests made with other contexts, e.g. by concurrent tests, are not captured
+		assert.NoError(t, get(context.TODO(), "other"))
+		assert.NoError(t, get(ctx, "hello"))
+		return errors.New("denied")
+	})
+	assert.EqualError(t, err, "denied")
+	assert.Equal(t, []string{"warning for hello"}, warnings)
+
+	warnings, err = CaptureWarnings(context.TODO(), cl, func(context.Context) error { return get(context.TODO(), "other") })
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"errors"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrResourceTypeNotFound is returned by GetAPIResource if the API server does not serve the kind.
+var ErrResourceTypeNotFound = errors.New("resource type not found")
+
+// MappingRefresher is implemented by clients whose REST mapping can be refreshed, e.g. once new CRDs are served.
+type MappingRefresher interface {
+	// RefreshMapping discards the cached REST mapping of the client, it is reloaded on its next use.
+	RefreshMapping()
+}
+
+// NewCachedDiscoveryClient returns a discovery client for cfg which caches the discovery information in memory until
+// it is invalidated.
+func NewCachedDiscoveryClient(cfg *rest.Config) (discovery.CachedDiscoveryInterface, error) {
+	dClient, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return memory.NewMemCacheClient(dClient), nil
+}
+
+// NewRESTMapper returns a REST mapper loaded from the cached discovery client dClient. Resetting the mapper
+// invalidates dClient, so that clients sharing both see the same API resources.
+func NewRESTMapper(dClient discovery.CachedDiscoveryInterface) meta.ResettableRESTMapper {
+	return restmapper.NewDeferredDiscoveryRESTMapper(dClient)
+}
+
+// RefreshMapping discards the cached REST mapping of cl, if it is a MappingRefresher or its REST mapper can be reset.
+// It returns whether the mapping was refreshed.
+func RefreshMapping(cl client.Client) bool {
+	if refresher, ok := cl.(MappingRefresher); ok {
+		refresher.RefreshMapping()
+		return true
+	}
+	if mapper, ok := cl.RESTMapper().(meta.ResettableRESTMapper); ok {
+		mapper.Reset()
+		return true
+	}
+	return false
+}
+
+// IsNoMatchError returns whether err is returned because the REST mapping of a client does not know a kind, which may
+// be stale.
+func IsNoMatchError(err error) bool {
+	var noKindMatch *meta.NoKindMatchError
+	var noResourceMatch *meta.NoResourceMatchError
+	return errors.As(err, &noKindMatch) || errors.As(err, &noResourceMatch)
+}
+
+// isStaleDiscoveryError returns whether err is returned because the discovery information of a client does not know a
+// group version or kind, which may be stale.
+func isStaleDiscoveryError(err error) bool {
+	return errors.Is(err, ErrResourceTypeNotFound) || errors.Is(err, memory.ErrCacheNotFound) || k8serrors.IsNotFound(err)
+}
+
+// WithMappingRefresh calls fn and, if it fails with a no-match error, refreshes the REST mapping of cl and calls fn
+// once more.
+func WithMappingRefresh(cl client.Client, fn func() error) error {
+	err := fn()
+	if IsNoMatchError(err) && RefreshMapping(cl) {
+		err = fn()
+	}
+	return err
+}
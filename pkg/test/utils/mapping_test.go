@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNamespacedRefreshesDiscovery(t *testing.T) {
+	fake := FakeDiscoveryClient().(*fakediscovery.FakeDiscovery)
+	cached := memory.NewMemCacheClient(fake)
+
+	_, _, err := Namespaced(cached, NewPod("hello", ""), "world")
+	assert.NoError(t, err)
+
+	// the CRD is installed once the discovery information is cached
+	fake.Resources = append(fake.Resources, &metav1.APIResourceList{
+		GroupVersion: "example.com/v1",
+		APIResources: []metav1.APIResource{{Name: "databases", Namespaced: true, Kind: "Database"}},
+	})
+
+	name, namespace, err := Namespaced(cached, NewResource("example.com/v1", "Database", "hello", ""), "world")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", name)
+	assert.Equal(t, "world", namespace)
+
+	_, _, err = Namespaced(cached, NewResource("example.com/v1", "Table", "hello", ""), "world")
+	assert.EqualError(t, err, "retrieving API resource for example.com/v1, Kind=Table failed: resource type not found")
+}
+
+func TestCreateOrUpdateRefreshesMapping(t *testing.T) {
+	database := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Database"}
+	cl := &staleMappingClient{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), stale: true}
+
+	updated, err := CreateOrUpdate(context.TODO(), cl, NewResource("example.com/v1", "Database", "hello", "world"), true)
+	assert.NoError(t, err)
+	assert.False(t, updated)
+	assert.Equal(t, 1, cl.refreshes)
+
+	actual := &unstructured.Unstructured{}
+	actual.SetGroupVersionKind(database)
+	assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Namespace: "world", Name: "hello"}, actual))
+
+	// calls which do not fail with a no-match error do not refresh the mapping
+	assert.NoError(t, WithMappingRefresh(cl, func() error { return nil }))
+	assert.Equal(t, 1, cl.refreshes)
+}
+
+func TestDryRunCreateOrUpdateRefreshesMapping(t *testing.T) {
+	cl := &staleMappingClient{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), stale: true}
+
+	result, updated, err := DryRunCreateOrUpdate(context.TODO(), cl, NewResource("example.com/v1", "Database", "hello", "world"))
+	assert.NoError(t, err)
+	assert.False(t, updated)
+	assert.Equal(t, "hello", result.GetName())
+	assert.Equal(t, 1, cl.refreshes)
+}
+
+func TestIsNoMatchError(t *testing.T) {
+	gk := schema.GroupKind{Group: "example.com", Kind: "Database"}
+
+	assert.True(t, IsNoMatchError(&meta.NoKindMatchError{GroupKind: gk}))
+	assert.True(t, IsNoMatchError(&meta.NoResourceMatchError{PartialResource: gk.WithVersion("v1").GroupVersion().WithResource("databases")}))
+	assert.False(t, IsNoMatchError(ErrResourceTypeNotFound))
+	assert.False(t, IsNoMatchError(nil))
+}
+
+// staleMappingClient is a client whose REST mapping does not map any kind until it is refreshed.
+type staleMappingClient struct {
+	client.Client
+	stale     bool
+	refreshes int
+}
+
+func (c *staleMappingClient) RefreshMapping() {
+	c.stale = false
+	c.refreshes++
+}
+
+func (c *staleMappingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if c.stale {
+		return &meta.NoKindMatchError{GroupKind: obj.GetObjectKind().GroupVersionKind().GroupKind()}
+	}
+	return c.Client.Get(ctx, key, obj)
+}
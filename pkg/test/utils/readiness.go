@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Readiness computes whether obj is ready using the status conventions of Kubernetes: the status must reflect the
+// latest generation of the object, workloads must be rolled out, jobs completed, and the Ready condition of other
+// objects, if any, must be true. It returns the reason why obj is not ready, if it is not.
+func Readiness(obj *unstructured.Unstructured) (bool, string) {
+	if obj.GetDeletionTimestamp() != nil {
+		return false, "the object is being deleted"
+	}
+
+	observedGeneration, found, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if found && observedGeneration < obj.GetGeneration() {
+		return false, fmt.Sprintf("the status reflects generation %d, not the latest generation %d", observedGeneration, obj.GetGeneration())
+	}
+
+	gvk := obj.GroupVersionKind()
+	switch {
+	case gvk.Group == "apps" && gvk.Kind == "Deployment":
+		return deploymentReadiness(obj)
+	case gvk.Group == "apps" && gvk.Kind == "StatefulSet":
+		return statefulSetReadiness(obj)
+	case gvk.Group == "apps" && gvk.Kind == "DaemonSet":
+		return daemonSetReadiness(obj)
+	case gvk.Group == "apps" && gvk.Kind == "ReplicaSet":
+		return replicasReadiness(obj, "readyReplicas")
+	case gvk.Group == "" && gvk.Kind == "ReplicationController":
+		return replicasReadiness(obj, "readyReplicas")
+	case gvk.Group == "batch" && gvk.Kind == "Job":
+		return jobReadiness(obj)
+	case gvk.Group == "" && gvk.Kind == "Pod":
+		return podReadiness(obj)
+	case gvk.Group == "" && gvk.Kind == "PersistentVolumeClaim":
+		return phaseReadiness(obj, "Bound")
+	case gvk.Group == "" && gvk.Kind == "Namespace":
+		return phaseReadiness(obj, "Active")
+	case gvk.Group == "" && gvk.Kind == "Service":
+		return serviceReadiness(obj)
+	case gvk.Group == "apiextensions.k8s.io" && gvk.Kind == "CustomResourceDefinition":
+		return conditionReadiness(obj, "Established")
+	}
+
+	return genericReadiness(obj)
+}
+
+func deploymentReadiness(obj *unstructured.Unstructured) (bool, string) {
+	if status, reason, message, found := condition(obj, "Progressing"); found && status == "False" && reason == "ProgressDeadlineExceeded" {
+		return false, fmt.Sprintf("the rollout exceeded its progress deadline: %s", message)
+	}
+
+	replicas := specReplicas(obj)
+	updated := statusInt(obj, "updatedReplicas")
+	current := statusInt(obj, "replicas")
+	available := statusInt(obj, "availableReplicas")
+
+	switch {
+	case updated < replicas:
+		return false, fmt.Sprintf("%d out of %d replicas have been updated", updated, replicas)
+	case current > updated:
+		return false, fmt.Sprintf("%d old replicas are pending termination", current-updated)
+	case available < replicas:
+		return false, fmt.Sprintf("%d out of %d replicas are available", available, replicas)
+	}
+	return true, ""
+}
+
+func statefulSetReadiness(obj *unstructured.Unstructured) (bool, string) {
+	replicas := specReplicas(obj)
+	ready := statusInt(obj, "readyReplicas")
+	if ready < replicas {
+		return false, fmt.Sprintf("%d out of %d replicas are ready", ready, replicas)
+	}
+
+	strategy, _, _ := unstructured.NestedString(obj.Object, "spec", "updateStrategy", "type")
+	if strategy == "OnDelete" {
+		return true, ""
+	}
+
+	partition, _, _ := unstructured.NestedInt64(obj.Object, "spec", "updateStrategy", "rollingUpdate", "partition")
+	if updated := statusInt(obj, "updatedReplicas"); updated < replicas-partition {
+		return false, fmt.Sprintf("%d out of %d replicas have been updated", updated, replicas-partition)
+	}
+	if partition == 0 {
+		currentRevision, _, _ := unstructured.NestedString(obj.Object, "status", "currentRevision")
+		updateRevision, _, _ := unstructured.NestedString(obj.Object, "status", "updateRevision")
+		if currentRevision != updateRevision {
+			return false, fmt.Sprintf("the rollout of revision %s is in progress", updateRevision)
+		}
+	}
+	return true, ""
+}
+
+func daemonSetReadiness(obj *unstructured.Unstructured) (bool, string) {
+	desired := statusInt(obj, "desiredNumberScheduled")
+	if updated := statusInt(obj, "updatedNumberScheduled"); updated < desired {
+		return false, fmt.Sprintf("%d out of %d pods have been updated", updated, desired)
+	}
+	if available := statusInt(obj, "numberAvailable"); available < desired {
+		return false, fmt.Sprintf("%d out of %d pods are available", available, desired)
+	}
+	return true, ""
+}
+
+func replicasReadiness(obj *unstructured.Unstructured, field string) (bool, string) {
+	replicas := specReplicas(obj)
+	if ready := statusInt(obj, field); ready < replicas {
+		return false, fmt.Sprintf("%d out of %d replicas are ready", ready, replicas)
+	}
+	return true, ""
+}
+
+func jobReadiness(obj *unstructured.Unstructured) (bool, string) {
+	if status, _, message, _ := condition(obj, "Failed"); status == "True" {
+		return false, fmt.Sprintf("the job failed: %s", message)
+	}
+	if status, _, _, _ := condition(obj, "Complete"); status == "True" {
+		return true, ""
+	}
+	return false, fmt.Sprintf("%d pods are active and %d succeeded", statusInt(obj, "active"), statusInt(obj, "succeeded"))
+}
+
+func podReadiness(obj *unstructured.Unstructured) (bool, string) {
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	switch phase {
+	case "Succeeded":
+		return true, ""
+	case "Failed":
+		reason, _, _ := unstructured.NestedString(obj.Object, "status", "reason")
+		return false, fmt.Sprintf("the pod failed: %s", reason)
+	}
+	return conditionReadiness(obj, "Ready")
+}
+
+func phaseReadiness(obj *unstructured.Unstructured, expected string) (bool, string) {
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	if phase != expected {
+		return false, fmt.Sprintf("the phase is %q instead of %q", phase, expected)
+	}
+	return true, ""
+}
+
+func serviceReadiness(obj *unstructured.Unstructured) (bool, string) {
+	serviceType, _, _ := unstructured.NestedString(obj.Object, "spec", "type")
+	if serviceType != "LoadBalancer" {
+		return true, ""
+	}
+	ingress, _, _ := unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress")
+	if len(ingress) == 0 {
+		return false, "no load balancer has been provisioned"
+	}
+	return true, ""
+}
+
+func conditionReadiness(obj *unstructured.Unstructured, conditionType string) (bool, string) {
+	status, reason, message, found := condition(obj, conditionType)
+	if !found {
+		return false, fmt.Sprintf("the %s condition is not set", conditionType)
+	}
+	if status != "True" {
+		return false, describeCondition(conditionType, status, reason, message)
+	}
+	return true, ""
+}
+
+// genericReadiness follows the conventions of the conditions of custom resources: the Reconciling and Stalled
+// conditions must not be true and the Ready condition, if any, must be true.
+func genericReadiness(obj *unstructured.Unstructured) (bool, string) {
+	for _, conditionType := range []string{"Stalled", "Reconciling"} {
+		if status, reason, message, _ := condition(obj, conditionType); status == "True" {
+			return false, describeCondition(conditionType, status, reason, message)
+		}
+	}
+	if _, _, _, found := condition(obj, "Ready"); found {
+		return conditionReadiness(obj, "Ready")
+	}
+	return true, ""
+}
+
+// condition returns the status, reason and message of the condition of obj of the given type, if found.
+func condition(obj *unstructured.Unstructured, conditionType string) (string, string, string, bool) {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		c, ok := c.(map[string]interface{})
+		if !ok || c["type"] != conditionType {
+			continue
+		}
+		status, _, _ := unstructured.NestedString(c, "status")
+		reason, _, _ := unstructured.NestedString(c, "reason")
+		message, _, _ := unstructured.NestedString(c, "message")
+		return status, reason, message, true
+	}
+	return "", "", "", false
+}
+
+func describeCondition(conditionType, status, reason, message string) string {
+	description := fmt.Sprintf("the %s condition is %s", conditionType, status)
+	if reason != "" {
+		description += fmt.Sprintf(" (%s)", reason)
+	}
+	if message != "" {
+		description += ": " + message
+	}
+	return description
+}
+
+// specReplicas returns the desired number of replicas of obj, it defaults to 1.
+func specReplicas(obj *unstructured.Unstructured) int64 {
+	replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if !found {
+		return 1
+	}
+	return replicas
+}
+
+func statusInt(obj *unstructured.Unstructured, field string) int64 {
+	value, _, _ := unstructured.NestedInt64(obj.Object, "status", field)
+	return value
+}
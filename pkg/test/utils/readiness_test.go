@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestReadiness(t *testing.T) {
+	newObject := func(apiVersion, kind string, spec, status map[string]interface{}) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": apiVersion,
+			"kind":       kind,
+			"metadata":   map[string]interface{}{"name": "hello", "generation": int64(2)},
+		}}
+		if spec != nil {
+			obj.Object["spec"] = spec
+		}
+		if status != nil {
+			obj.Object["status"] = status
+		}
+		return obj
+	}
+	conditions := func(conditions ...map[string]interface{}) []interface{} {
+		result := []interface{}{}
+		for _, c := range conditions {
+			result = append(result, c)
+		}
+		return result
+	}
+
+	deleted := newObject("v1", "ConfigMap", nil, nil)
+	now := metav1.Now()
+	deleted.SetDeletionTimestamp(&now)
+
+	for _, test := range []struct {
+		name           string
+		obj            *unstructured.Unstructured
+		expectedReady  bool
+		expectedReason string
+	}{
+		{
+			name:          "object without status",
+			obj:           newObject("v1", "ConfigMap", nil, nil),
+			expectedReady: true,
+		},
+		{
+			name:           "object being deleted",
+			obj:            deleted,
+			expectedReason: "the object is being deleted",
+		},
+		{
+			name:           "status of a previous generation",
+			obj:            newObject("example.com/v1", "Database", nil, map[string]interface{}{"observedGeneration": int64(1)}),
+			expectedReason: "the status reflects generation 1, not the latest generation 2",
+		},
+		{
+			name: "rolled out deployment",
+			obj: newObject("apps/v1", "Deployment", map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{
+				"observedGeneration": int64(2), "replicas": int64(2), "updatedReplicas": int64(2), "availableReplicas": int64(2),
+			}),
+			expectedReady: true,
+		},
+		{
+			name: "deployment with old replicas",
+			obj: newObject("apps/v1", "Deployment", map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{
+				"observedGeneration": int64(2), "replicas": int64(3), "updatedReplicas": int64(2), "availableReplicas": int64(2),
+			}),
+			expectedReason: "1 old replicas are pending termination",
+		},
+		{
+			name: "deployment with unavailable replicas",
+			obj: newObject("apps/v1", "Deployment", nil, map[string]interface{}{
+				"replicas": int64(1), "updatedReplicas": int64(1),
+			}),
+			expectedReason: "0 out of 1 replicas are available",
+		},
+		{
+			name: "deployment exceeding its progress deadline",
+			obj: newObject("apps/v1", "Deployment", nil, map[string]interface{}{
+				"conditions": conditions(map[string]interface{}{
+					"type": "Progressing", "status": "False", "reason": "ProgressDeadlineExceeded", "message": `ReplicaSet "hello-5d9c" has timed out progressing.`,
+				}),
+			}),
+			expectedReason: `the rollout exceeded its progress deadline: ReplicaSet "hello-5d9c" has timed out progressing.`,
+		},
+		{
+			name: "statefulset rolling out a revision",
+			obj: newObject("apps/v1", "StatefulSet", map[string]interface{}{"replicas": int64(1)}, map[string]interface{}{
+				"readyReplicas": int64(1), "updatedReplicas": int64(1), "currentRevision": "hello-1", "updateRevision": "hello-2",
+			}),
+			expectedReason: "the rollout of revision hello-2 is in progress",
+		},
+		{
+			name: "daemonset with outdated pods",
+			obj: newObject("apps/v1", "DaemonSet", nil, map[string]interface{}{
+				"desiredNumberScheduled": int64(3), "updatedNumberScheduled": int64(2), "numberAvailable": int64(3),
+			}),
+			expectedReason: "2 out of 3 pods have been updated",
+		},
+		{
+			name:          "completed job",
+			obj:           newObject("batch/v1", "Job", nil, map[string]interface{}{"conditions": conditions(map[string]interface{}{"type": "Complete", "status": "True"})}),
+			expectedReady: true,
+		},
+		{
+			name:           "failed job",
+			obj:            newObject("batch/v1", "Job", nil, map[string]interface{}{"conditions": conditions(map[string]interface{}{"type": "Failed", "status": "True", "message": "Job has reached the specified backoff limit"})}),
+			expectedReason: "the job failed: Job has reached the specified backoff limit",
+		},
+		{
+			name:           "active job",
+			obj:            newObject("batch/v1", "Job", nil, map[string]interface{}{"active": int64(1)}),
+			expectedReason: "1 pods are active and 0 succeeded",
+		},
+		{
+			name:          "ready pod",
+			obj:           newObject("v1", "Pod", nil, map[string]interface{}{"phase": "Running", "conditions": conditions(map[string]interface{}{"type": "Ready", "status": "True"})}),
+			expectedReady: true,
+		},
+		{
+			name: "pod with unready containers",
+			obj: newObject("v1", "Pod", nil, map[string]interface{}{"phase": "Running", "conditions": conditions(map[string]interface{}{
+				"type": "Ready", "status": "False", "reason": "ContainersNotReady", "message": "containers with unready status: [hello]",
+			})}),
+			expectedReason: "the Ready condition is False (ContainersNotReady): containers with unready status: [hello]",
+		},
+		{
+			name:           "pending persistent volume claim",
+			obj:            newObject("v1", "PersistentVolumeClaim", nil, map[string]interface{}{"phase": "Pending"}),
+			expectedReason: `the phase is "Pending" instead of "Bound"`,
+		},
+		{
+			name:           "load balancer without ingress",
+			obj:            newObject("v1", "Service", map[string]interface{}{"type": "LoadBalancer"}, nil),
+			expectedReason: "no load balancer has been provisioned",
+		},
+		{
+			name:           "custom resource definition not established",
+			obj:            newObject("apiextensions.k8s.io/v1", "CustomResourceDefinition", nil, nil),
+			expectedReason: "the Established condition is not set",
+		},
+		{
+			name:          "ready custom resource",
+			obj:           newObject("example.com/v1", "Database", nil, map[string]interface{}{"observedGeneration": int64(2), "conditions": conditions(map[string]interface{}{"type": "Ready", "status": "True"})}),
+			expectedReady: true,
+		},
+		{
+			name: "reconciling custom resource",
+			obj: newObject("example.com/v1", "Database", nil, map[string]interface{}{"conditions": conditions(
+				map[string]interface{}{"type": "Reconciling", "status": "True", "reason": "Provisioning"},
+				map[string]interface{}{"type": "Ready", "status": "True"},
+			)}),
+			expectedReason: "the Reconciling condition is True (Provisioning)",
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			ready, reason := Readiness(test.obj)
+			assert.Equal(t, test.expectedReady, ready)
+			assert.Equal(t, test.expectedReason, reason)
+		})
+	}
+}
@@ -2,7 +2,14 @@ package utils
 
 import (
 	"fmt"
+	"math/big"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 type ArrayComparisonStrategyFactory = func(path string) ArrayComparisonStrategy
@@ -18,8 +25,10 @@ func alwaysExact(path string) ArrayComparisonStrategy {
 
 // SubsetError is an error type used by IsSubset for tracking the path in the struct.
 type SubsetError struct {
-	path    []string
-	message string
+	path     []string
+	message  string
+	expected interface{}
+	actual   interface{}
 }
 
 // AppendPath appends key to the existing struct path. For example, in struct member `a.Key1.Key2`, the path would be ["Key1", "Key2"]
@@ -31,26 +40,107 @@ func (e *SubsetError) AppendPath(key string) {
 	e.path = append(e.path, key)
 }
 
+// Path returns the JSON pointer (RFC 6901) to the mismatching value, for example `/spec/containers/0/image`.
+func (e *SubsetError) Path() string {
+	path := ""
+	for i := len(e.path) - 1; i >= 0; i-- {
+		segment := strings.ReplaceAll(strings.ReplaceAll(e.path[i], "~", "~0"), "/", "~1")
+		path = fmt.Sprintf("%s/%s", path, segment)
+	}
+	return path
+}
+
+// Expected returns the expected value at Path.
+func (e *SubsetError) Expected() interface{} {
+	return e.expected
+}
+
+// Actual returns the actual value at Path, nil if it is missing.
+func (e *SubsetError) Actual() interface{} {
+	return e.actual
+}
+
 // Error implements the error interface.
 func (e *SubsetError) Error() string {
-	if e.path == nil || len(e.path) == 0 {
+	if len(e.path) == 0 {
 		return e.message
 	}
 
-	path := ""
-	for i := len(e.path) - 1; i >= 0; i-- {
-		path = fmt.Sprintf("%s.%s", path, e.path[i])
+	return fmt.Sprintf("%s: %s", e.Path(), e.message)
+}
+
+// SubsetErrors is returned by IsSubset when more than one mismatch is found.
+type SubsetErrors []*SubsetError
+
+// Error implements the error interface.
+func (e SubsetErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("%d mismatches found:\n%s", len(e), strings.Join(messages, "\n"))
+}
+
+// AsSubsetErrors returns all of the mismatches contained in err, or nil if err is not returned by IsSubset.
+func AsSubsetErrors(err error) SubsetErrors {
+	switch e := err.(type) {
+	case *SubsetError:
+		return SubsetErrors{e}
+	case SubsetErrors:
+		return e
 	}
+	return nil
+}
 
-	return fmt.Sprintf("%s: %s", path, e.message)
+// appendSubsetError prefixes the path of the mismatches in err with key and appends them to errs.
+// If err is not returned by IsSubset, it is returned as is.
+func appendSubsetError(errs SubsetErrors, err error, key string) (SubsetErrors, error) {
+	mismatches := AsSubsetErrors(err)
+	if mismatches == nil {
+		return errs, err
+	}
+	for _, mismatch := range mismatches {
+		mismatch.AppendPath(key)
+	}
+	return append(errs, mismatches...), nil
+}
+
+// toError returns nil if there are no mismatches, the mismatch itself if there is only one and all mismatches otherwise.
+func (e SubsetErrors) toError() error {
+	switch len(e) {
+	case 0:
+		return nil
+	case 1:
+		return e[0]
+	}
+	return e
 }
 
 // IsSubset checks to see if `expected` is a subset of `actual`. A "subset" is an object that is equivalent to
 // the other object, but where map keys found in actual that are not defined in expected are ignored.
+// Map keys wrapped in parentheses are JMESPath expressions evaluated against the actual map, the result
+// of the expression must match the expected value.
+// Scalar values are compared semantically, so numbers of different types, Kubernetes quantities (`1Gi` and `1024Mi`)
+// and durations (`1m` and `60s`) with the same meaning are considered equal. Numbers are compared exactly, and only
+// match strings carrying a quantity suffix, e.g. `1Gi` matches 1073741824 but `5` does not match 5.
+// All mismatches are collected, the returned error is a *SubsetError if there is one and SubsetErrors if there are more.
 func IsSubset(expected, actual interface{}, currentPath string, strategyFactory ArrayComparisonStrategyFactory) error {
+	if equal, ok := semanticEqual(expected, actual); ok {
+		if equal {
+			return nil
+		}
+		return &SubsetError{
+			message:  fmt.Sprintf("value mismatch, expected: %v != actual: %v", expected, actual),
+			expected: expected,
+			actual:   actual,
+		}
+	}
+
 	if reflect.TypeOf(expected) != reflect.TypeOf(actual) {
 		return &SubsetError{
-			message: fmt.Sprintf("type mismatch: %v != %v", reflect.TypeOf(expected), reflect.TypeOf(actual)),
+			message:  fmt.Sprintf("type mismatch: %v != %v", reflect.TypeOf(expected), reflect.TypeOf(actual)),
+			expected: expected,
+			actual:   actual,
 		}
 	}
 
@@ -69,33 +159,68 @@ func IsSubset(expected, actual interface{}, currentPath string, strategyFactory
 
 	case reflect.Map:
 		iter := reflect.ValueOf(expected).MapRange()
+		errs := SubsetErrors{}
 
 		for iter.Next() {
-			actualValue := reflect.ValueOf(actual).MapIndex(iter.Key())
+			var err error
 
-			if !actualValue.IsValid() {
-				return &SubsetError{
-					path:    []string{iter.Key().String()},
-					message: "key is missing from map",
+			key := iter.Key().String()
+			if k, ok := iter.Key().Interface().(string); ok && IsExpression(k) {
+				err = isSubsetExpression(k, iter.Value().Interface(), actual)
+			} else if actualValue := reflect.ValueOf(actual).MapIndex(iter.Key()); !actualValue.IsValid() {
+				err = &SubsetError{
+					message:  "key is missing from map",
+					expected: iter.Value().Interface(),
 				}
+			} else {
+				newPath := currentPath + "/" + key
+				err = IsSubset(iter.Value().Interface(), actualValue.Interface(), newPath, strategyFactory)
 			}
 
-			newPath := currentPath + "/" + iter.Key().String()
-			if err := IsSubset(iter.Value().Interface(), actualValue.Interface(), newPath, strategyFactory); err != nil {
-				subsetErr, ok := err.(*SubsetError)
-				if ok {
-					subsetErr.AppendPath(iter.Key().String())
-					return subsetErr
+			if err != nil {
+				if errs, err = appendSubsetError(errs, err, key); err != nil {
+					return err
 				}
-				return err
 			}
 		}
+
+		sortSubsetErrors(errs)
+		return errs.toError()
 	default:
 		return &SubsetError{
-			message: fmt.Sprintf("value mismatch, expected: %v != actual: %v", expected, actual),
+			message:  fmt.Sprintf("value mismatch, expected: %v != actual: %v", expected, actual),
+			expected: expected,
+			actual:   actual,
 		}
 	}
+}
 
+// sortSubsetErrors sorts mismatches by path, so that the output does not depend on map iteration order.
+func sortSubsetErrors(errs SubsetErrors) {
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Path() < errs[j].Path()
+	})
+}
+
+// isSubsetExpression evaluates the expression key against actual and checks that the result matches expected.
+func isSubsetExpression(key string, expected, actual interface{}) error {
+	result, err := EvaluateExpression(key, actual)
+	if err != nil {
+		return &SubsetError{message: err.Error()}
+	}
+
+	expected, err = normalizeJSON(expected)
+	if err != nil {
+		return err
+	}
+
+	if err := IsSubset(expected, result, "", nil); err != nil {
+		return &SubsetError{
+			message:  fmt.Sprintf("expression mismatch, expected: %v != actual: %v", expected, result),
+			expected: expected,
+			actual:   result,
+		}
+	}
 	return nil
 }
 
@@ -103,6 +228,7 @@ func StrategyAnywhere(path string, strategyFactory ArrayComparisonStrategyFactor
 	return func(expected, actual interface{}) error {
 		expectedData := toSlice(expected)
 		actualData := toSlice(actual)
+		errs := SubsetErrors{}
 
 		for i, expectedItem := range expectedData {
 			matched := false
@@ -114,26 +240,162 @@ func StrategyAnywhere(path string, strategyFactory ArrayComparisonStrategyFactor
 				}
 			}
 			if !matched {
-				return &SubsetError{message: fmt.Sprintf("expected item %v not found in actual slice at path %s", expectedItem, path)}
+				errs = append(errs, &SubsetError{
+					path:     []string{strconv.Itoa(i)},
+					message:  fmt.Sprintf("expected item %v not found in actual slice at path %s", expectedItem, path),
+					expected: expectedItem,
+				})
 			}
 		}
-		return nil
+		return errs.toError()
 	}
 }
 
 func StrategyExact(path string, strategyFactory ArrayComparisonStrategyFactory) ArrayComparisonStrategy {
 	return func(expected, actual interface{}) error {
 		if reflect.ValueOf(expected).Len() != reflect.ValueOf(actual).Len() {
-			return &SubsetError{message: fmt.Sprintf("slice length mismatch at path %s: %d != %d", path, reflect.ValueOf(expected).Len(), reflect.ValueOf(actual).Len())}
+			return &SubsetError{
+				message:  fmt.Sprintf("slice length mismatch at path %s: %d != %d", path, reflect.ValueOf(expected).Len(), reflect.ValueOf(actual).Len()),
+				expected: expected,
+				actual:   actual,
+			}
 		}
+		errs := SubsetErrors{}
 		for i := 0; i < reflect.ValueOf(expected).Len(); i++ {
 			newPath := path + fmt.Sprintf("[%d]", i)
 			if err := IsSubset(reflect.ValueOf(expected).Index(i).Interface(), reflect.ValueOf(actual).Index(i).Interface(), newPath, strategyFactory); err != nil {
-				return err
+				var appendErr error
+				if errs, appendErr = appendSubsetError(errs, err, strconv.Itoa(i)); appendErr != nil {
+					return appendErr
+				}
 			}
 		}
-		return nil
+		return errs.toError()
+	}
+}
+
+// semanticEqual compares two scalar values which may have a different representation but the same meaning.
+// ok is false if the values can not be compared semantically, in which case they are compared as is.
+func semanticEqual(expected, actual interface{}) (equal bool, ok bool) {
+	if e, ok := toNumber(expected); ok {
+		if a, ok := toNumber(actual); ok {
+			return e.Cmp(a) == 0, true
+		}
+	}
+
+	// quantities are only compared when at least one side is a string with a unit, otherwise plain numeric
+	// strings like "1.10" and "1.1", or a number and a numeric string written with the wrong type, would be
+	// considered equal.
+	if hasUnit(expected) || hasUnit(actual) {
+		if e, ok := toQuantity(expected); ok {
+			if a, ok := toQuantity(actual); ok {
+				return e.Cmp(a) == 0, true
+			}
+		}
+	}
+
+	if e, ok := toDuration(expected); ok {
+		if a, ok := toDuration(actual); ok {
+			return e == a, true
+		}
+	}
+
+	return false, false
+}
+
+// toNumber converts integer and floating point values to a big.Rat for exact comparison.
+func toNumber(v interface{}) (*big.Rat, bool) {
+	switch n := v.(type) {
+	case int, int8, int16, int32, int64:
+		return new(big.Rat).SetInt64(reflect.ValueOf(n).Int()), true
+	case uint, uint8, uint16, uint32, uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(reflect.ValueOf(n).Uint())), true
+	case float32, float64:
+		// SetFloat64 returns nil for infinite values and NaN, which are not numbers to compare
+		r := new(big.Rat).SetFloat64(reflect.ValueOf(n).Float())
+		return r, r != nil
+	}
+	return nil, false
+}
+
+// toQuantity converts numbers and quantity strings such as `1Gi` to a resource.Quantity.
+func toQuantity(v interface{}) (resource.Quantity, bool) {
+	switch n := v.(type) {
+	case string:
+		q, err := resource.ParseQuantity(n)
+		return q, err == nil
+	case int, int8, int16, int32, int64:
+		return *resource.NewQuantity(reflect.ValueOf(n).Int(), resource.DecimalSI), true
+	case uint, uint8, uint16, uint32, uint64:
+		q, err := resource.ParseQuantity(strconv.FormatUint(reflect.ValueOf(n).Uint(), 10))
+		return q, err == nil
+	case float32, float64:
+		q, err := resource.ParseQuantity(strconv.FormatFloat(reflect.ValueOf(n).Float(), 'f', -1, 64))
+		return q, err == nil
+	}
+	return resource.Quantity{}, false
+}
+
+// toDuration converts duration strings such as `1m30s` to a time.Duration.
+func toDuration(v interface{}) (time.Duration, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return 0, false
+	}
+	d, err := time.ParseDuration(s)
+	return d, err == nil
+}
+
+// hasUnit returns true if v is a string which is not a plain number, e.g. `1Gi`.
+func hasUnit(v interface{}) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err != nil
+}
+
+// pruneToSubset returns a copy of actual that only contains the map keys defined in expected, so that a diff
+// between expected and the result only shows the values which actually differ.
+// Expression keys are replaced with the result of the expression evaluated against actual.
+func pruneToSubset(expected, actual interface{}) interface{} {
+	if expected == nil || actual == nil || reflect.TypeOf(expected).Kind() != reflect.TypeOf(actual).Kind() {
+		return actual
+	}
+
+	switch reflect.TypeOf(expected).Kind() {
+	case reflect.Map:
+		pruned := map[string]interface{}{}
+		iter := reflect.ValueOf(expected).MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			if IsExpression(key) {
+				if result, err := EvaluateExpression(key, actual); err == nil {
+					pruned[key] = result
+				}
+				continue
+			}
+			actualValue := reflect.ValueOf(actual).MapIndex(iter.Key())
+			if !actualValue.IsValid() {
+				continue
+			}
+			pruned[key] = pruneToSubset(iter.Value().Interface(), actualValue.Interface())
+		}
+		return pruned
+	case reflect.Slice:
+		expectedData := toSlice(expected)
+		actualData := toSlice(actual)
+		if len(expectedData) != len(actualData) {
+			return actual
+		}
+		pruned := make([]interface{}, len(actualData))
+		for i := range actualData {
+			pruned[i] = pruneToSubset(expectedData[i], actualData[i])
+		}
+		return pruned
 	}
+	return actual
 }
 
 func toSlice(v interface{}) []interface{} {
@@ -196,3 +196,140 @@ func TestIsSubset(t *testing.T) {
 		},
 		"", DefaultStrategyFactory()))
 }
+
+func TestIsSubsetExpression(t *testing.T) {
+	actual := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+		},
+		"status": map[string]interface{}{
+			"readyReplicas": int64(3),
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Available", "status": "True"},
+			},
+		},
+	}
+
+	assert.Nil(t, IsSubset(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"(replicas >= `2`)": true,
+		},
+	}, actual, "", DefaultStrategyFactory()))
+
+	assert.Nil(t, IsSubset(map[string]interface{}{
+		"(status.readyReplicas == spec.replicas)": true,
+	}, actual, "", DefaultStrategyFactory()))
+
+	assert.Nil(t, IsSubset(map[string]interface{}{
+		"status": map[string]interface{}{
+			"(conditions[?type == 'Available'].status)": []interface{}{"True"},
+		},
+	}, actual, "", DefaultStrategyFactory()))
+
+	assert.Nil(t, IsSubset(map[string]interface{}{
+		"(spec.replicas)": int64(3),
+	}, actual, "", DefaultStrategyFactory()))
+
+	assert.EqualError(t, IsSubset(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"(replicas > `3`)": true,
+		},
+	}, actual, "", DefaultStrategyFactory()), "/spec/(replicas > `3`): expression mismatch, expected: true != actual: false")
+
+	assert.NotNil(t, IsSubset(map[string]interface{}{
+		"(spec.replicas ==": true,
+	}, actual, "", DefaultStrategyFactory()))
+
+	assert.NotNil(t, IsSubset(map[string]interface{}{
+		"(spec.[)": true,
+	}, actual, "", DefaultStrategyFactory()))
+}
+
+func TestIsSubsetSemantic(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		expected interface{}
+		actual   interface{}
+		match    bool
+	}{
+		{"int and float", int64(2), float64(2), true},
+		{"int and float mismatch", int64(2), 2.5, false},
+		{"int types", int(2), int64(2), true},
+		{"uint and int", uint32(7), int64(7), true},
+		{"quantity and bytes", "1Gi", int64(1073741824), true},
+		{"quantity and bytes string", "1Gi", "1073741824", true},
+		{"quantity units", "1Gi", "1024Mi", true},
+		{"quantity cpu", "500m", "0.5", true},
+		{"quantity mismatch", "1Gi", "1G", false},
+		{"number and numeric string", int64(5), "5", false},
+		{"float precision", 0.1234567891, 0.1234567892, false},
+		{"small floats", 1e-12, 5e-12, false},
+		{"large ints", int64(9007199254740993), float64(9007199254740992), false},
+		{"large uint", uint64(18446744073709551615), uint64(18446744073709551615), true},
+		{"plain numeric strings", "1.10", "1.1", false},
+		{"durations", "1m", "60s", true},
+		{"durations mismatch", "1m", "61s", false},
+		{"durations hours", "1h", "60m", true},
+		{"strings", "hello", "hello", true},
+		{"string mismatch", "hello", "world", false},
+		{"bool and string", true, "true", false},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := IsSubset(map[string]interface{}{"value": tt.expected}, map[string]interface{}{"value": tt.actual}, "", DefaultStrategyFactory())
+			if tt.match {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestIsSubsetCollectsAllMismatches(t *testing.T) {
+	err := IsSubset(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name": "hello",
+			"labels": map[string]interface{}{
+				"app.kubernetes.io/name": "world",
+			},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"containers": []interface{}{
+				map[string]interface{}{"image": "nginx"},
+				map[string]interface{}{"image": "busybox"},
+			},
+		},
+	}, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name": "hello",
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+			"containers": []interface{}{
+				map[string]interface{}{"image": "nginx"},
+				map[string]interface{}{"image": "alpine"},
+			},
+		},
+	}, "", DefaultStrategyFactory())
+
+	mismatches := AsSubsetErrors(err)
+	assert.Len(t, mismatches, 3)
+
+	paths := []string{}
+	for _, mismatch := range mismatches {
+		paths = append(paths, mismatch.Path())
+	}
+	assert.Equal(t, []string{"/metadata/labels", "/spec/containers/1/image", "/spec/replicas"}, paths)
+
+	assert.Equal(t, "busybox", mismatches[1].Expected())
+	assert.Equal(t, "alpine", mismatches[1].Actual())
+	assert.Equal(t, int64(3), mismatches[2].Expected())
+	assert.Equal(t, int64(1), mismatches[2].Actual())
+
+	assert.EqualError(t, err, `3 mismatches found:
+/metadata/labels: key is missing from map
+/spec/containers/1/image: value mismatch, expected: busybox != actual: alpine
+/spec/replicas: value mismatch, expected: 3 != actual: 1`)
+}
@@ -0,0 +1,425 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+const (
+	// resyncPeriod is the interval in which the checks are re-evaluated if no relevant object changed.
+	resyncPeriod = 5 * time.Second
+	// pollPeriod is the interval in which the checks are re-evaluated if the objects can not be watched.
+	pollPeriod = time.Second
+)
+
+// watchTarget identifies a set of objects which are watched for changes.
+type watchTarget struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	// labels and fields are the label and field selectors of the objects.
+	labels string
+	fields string
+}
+
+// errorAssertion is an error assertion of a step along with the objects it applies to.
+type errorAssertion struct {
+	target   watchTarget
+	expected map[string]interface{}
+}
+
+// changeNotifier watches the objects referenced by the asserts and errors of a test step, so that the
+// checks of the step are only re-evaluated when one of those objects changed. If an object can not be
+// watched, the notifier falls back to polling.
+//
+// If the error assertions of the step are invariants, every observed object is matched against them, so that an
+// object matching an error assertion is recorded even if it only existed between two checks.
+type changeNotifier struct {
+	changed chan struct{}
+	cancel  context.CancelFunc
+	logger  testutils.Logger
+	// aborted is done once an object matched an invariant error assertion, or once the notifier is stopped.
+	aborted context.Context
+	abort   context.CancelFunc
+
+	// invariant is true if the error assertions of the step must hold for the whole step.
+	invariant bool
+	// errorAssertions are the namespaced error assertions of the step, if they are invariants.
+	errorAssertions []errorAssertion
+	// existing are the resource versions of the objects matched by the error assertions before the step started,
+	// by uid. These objects are only violations once they changed.
+	existing map[types.UID]string
+
+	lock      sync.Mutex
+	polling   bool
+	violation *errorAssertionError
+}
+
+// watchChanges starts watching the objects referenced by the asserts and errors of the test step.
+// The returned notifier must be stopped once the step is done.
+func (s *Step) watchChanges(namespace string) *changeNotifier {
+	ctx, cancel := context.WithCancel(context.Background())
+	aborted, abort := context.WithCancel(context.Background())
+	notifier := &changeNotifier{
+		changed:   make(chan struct{}, 1),
+		cancel:    cancel,
+		logger:    s.Logger,
+		aborted:   aborted,
+		abort:     abort,
+		invariant: s.Assert == nil || s.Assert.ErrorsInvariant == nil || *s.Assert.ErrorsInvariant,
+		// assert commands, access checks, absent objects and ownership checks are not watched
+		polling: s.Assert != nil && (len(s.Assert.Commands) > 0 || len(s.Assert.Access) > 0 || len(s.Assert.Absent) > 0 ||
+			len(s.Assert.Ownership) > 0),
+	}
+
+	cl, err := s.Client(false)
+	if err != nil {
+		notifier.fallback(err)
+		return notifier
+	}
+
+	targets, err := s.watchTargets(namespace)
+	if err != nil {
+		notifier.fallback(err)
+		return notifier
+	}
+
+	if notifier.invariant {
+		notifier.errorAssertions, err = s.errorAssertions(namespace)
+		if err != nil {
+			notifier.fallback(err)
+			return notifier
+		}
+
+		// the objects are listed before they are watched, so that the initial events of the watches for the
+		// existing objects are not taken for violations
+		notifier.existing, err = existingObjects(cl, notifier.errorAssertions)
+		if err != nil {
+			notifier.fallback(err)
+			return notifier
+		}
+	}
+
+	for _, target := range targets {
+		go notifier.watch(ctx, cl, target)
+	}
+
+	return notifier
+}
+
+// watchTargets returns the distinct sets of objects referenced by the asserts and errors.
+func (s *Step) watchTargets(namespace string) ([]watchTarget, error) {
+	seen := map[watchTarget]bool{}
+	targets := []watchTarget{}
+
+	for _, expected := range append(append([]asserts{}, s.Asserts...), s.Errors...) {
+		target, err := s.watchTarget(expected, namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		if !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+
+	return targets, nil
+}
+
+// watchTarget returns the set of objects which expected is compared against.
+func (s *Step) watchTarget(expected asserts, namespace string) (watchTarget, error) {
+	dClient, err := s.DiscoveryClient()
+	if err != nil {
+		return watchTarget{}, err
+	}
+
+	_, objNs, err := testutils.Namespaced(dClient, expected.object.DeepCopyObject(), namespace)
+	if err != nil {
+		return watchTarget{}, err
+	}
+
+	sel, err := newSelector(expected.object, expected.options)
+	if err != nil {
+		return watchTarget{}, err
+	}
+
+	return watchTarget{
+		gvk:       expected.object.GetObjectKind().GroupVersionKind(),
+		namespace: objNs,
+		labels:    sel.labels.String(),
+		fields:    sel.fields.String(),
+	}, nil
+}
+
+// errorAssertions returns the error assertions of the step with the namespace set.
+func (s *Step) errorAssertions(namespace string) ([]errorAssertion, error) {
+	dClient, err := s.DiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+
+	errorAssertions := []errorAssertion{}
+
+	for _, e := range s.Errors {
+		target, err := s.watchTarget(e, namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		expected := e.object.DeepCopyObject()
+		if _, _, err := testutils.Namespaced(dClient, expected, namespace); err != nil {
+			return nil, err
+		}
+
+		expectedObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(expected)
+		if err != nil {
+			return nil, err
+		}
+		errorAssertions = append(errorAssertions, errorAssertion{target: target, expected: expectedObj})
+	}
+
+	return errorAssertions, nil
+}
+
+// existingObjects returns the resource versions of the objects targeted by errorAssertions, by uid.
+func existingObjects(cl client.Client, errorAssertions []errorAssertion) (map[types.UID]string, error) {
+	existing := map[types.UID]string{}
+	for _, assertion := range errorAssertions {
+		objs, err := list(cl, assertion.target.gvk, assertion.target.namespace, assertion.target.selector())
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objs {
+			existing[obj.GetUID()] = obj.GetResourceVersion()
+		}
+	}
+	return existing, nil
+}
+
+// selector returns the selector of the objects of target.
+func (t watchTarget) selector() selector {
+	// the selectors were built from parsed selectors and can not fail to parse
+	sel := selector{}
+	sel.labels, _ = labels.Parse(t.labels)
+	sel.fields, _ = fields.ParseSelector(t.fields)
+	return sel
+}
+
+// watch notifies about every change of the objects of target until ctx is done.
+// Watches closed by the API server are resumed from the last seen resource version, so that no change is missed.
+// If that resource version expired, the watch is restarted without one: the API server then sends the current state
+// of all objects of target, which are checked again.
+func (n *changeNotifier) watch(ctx context.Context, cl client.Client, target watchTarget) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(target.gvk)
+
+	listOptions := target.selector().listOptions()
+	if target.namespace != "" {
+		listOptions = append(listOptions, client.InNamespace(target.namespace))
+	}
+
+	resourceVersion := ""
+	for {
+		w, err := testutils.WatchList(ctx, cl, list, append(listOptions, resumeFrom(resourceVersion))...)
+		if err != nil {
+			if ctx.Err() == nil {
+				n.fallback(err)
+			}
+			return
+		}
+
+		lastSeen, open := n.forward(ctx, w, target, resourceVersion)
+		if !open {
+			return
+		}
+
+		// do not restart watches which are closed right away in a busy loop
+		if lastSeen == resourceVersion {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollPeriod):
+			}
+		}
+		resourceVersion = lastSeen
+	}
+}
+
+// resumeFrom returns the list option to start a watch at resourceVersion, with bookmarks so that the resource
+// version is kept up to date while no object changes.
+func resumeFrom(resourceVersion string) client.ListOption {
+	return &client.ListOptions{Raw: &metav1.ListOptions{ResourceVersion: resourceVersion, AllowWatchBookmarks: true}}
+}
+
+// forward notifies about all events of w for the objects of target, w was started at resourceVersion.
+// It returns the last seen resource version and false once ctx is done, or true if the watch was closed. The
+// resource version is empty if it expired.
+func (n *changeNotifier) forward(ctx context.Context, w watch.Interface, target watchTarget, resourceVersion string) (string, bool) {
+	defer w.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return resourceVersion, false
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return resourceVersion, true
+			}
+
+			switch event.Type {
+			case watch.Error:
+				if err := apierrors.FromObject(event.Object); apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+					return "", true
+				}
+				continue
+			case watch.Added, watch.Modified:
+				n.observe(event.Object, target)
+			}
+			if accessor, err := meta.Accessor(event.Object); err == nil {
+				resourceVersion = accessor.GetResourceVersion()
+			}
+			if event.Type != watch.Bookmark {
+				n.notify()
+			}
+		}
+	}
+}
+
+// observe records the first observed object of target which matches an error assertion for target.
+// Objects which did not change since the step started are ignored.
+func (n *changeNotifier) observe(obj runtime.Object, target watchTarget) {
+	if len(n.errorAssertions) == 0 {
+		return
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return
+	}
+	actual := unstructured.Unstructured{Object: content}
+	// typed objects may be decoded without their type meta
+	actual.SetGroupVersionKind(target.gvk)
+	if n.preexisting(&actual) {
+		return
+	}
+
+	for _, assertion := range n.errorAssertions {
+		if assertion.target != target {
+			continue
+		}
+		if err := testutils.IsSubset(assertion.expected, actual.UnstructuredContent(), "/", nil); err != nil {
+			continue
+		}
+
+		n.lock.Lock()
+		if n.violation == nil {
+			n.violation = &errorAssertionError{objects: []unstructured.Unstructured{actual}}
+		}
+		n.lock.Unlock()
+		n.abort()
+		return
+	}
+}
+
+// preexisting returns true if obj existed unchanged before the step started.
+func (n *changeNotifier) preexisting(obj *unstructured.Unstructured) bool {
+	resourceVersion, ok := n.existing[obj.GetUID()]
+	return ok && resourceVersion == obj.GetResourceVersion()
+}
+
+// violated returns true if errs contain objects matching invariant error assertions which changed since the step
+// started. Error assertions which are not invariants are re-checked until the timeout of the step instead.
+func (n *changeNotifier) violated(errs []error) bool {
+	if !n.invariant {
+		return false
+	}
+	for i := range errs {
+		var matched *errorAssertionError
+		if !errors.As(errs[i], &matched) {
+			continue
+		}
+		for j := range matched.objects {
+			if !n.preexisting(&matched.objects[j]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// notify records a change without blocking, pending changes are coalesced.
+func (n *changeNotifier) notify() {
+	select {
+	case n.changed <- struct{}{}:
+	default:
+	}
+}
+
+// fallback switches the notifier to polling.
+func (n *changeNotifier) fallback(err error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if !n.polling {
+		n.logger.Logf("unable to watch objects, falling back to polling: %v", err)
+	}
+	n.polling = true
+}
+
+// wait blocks until a watched object changed, the resync (or poll) period elapsed or at most max.
+func (n *changeNotifier) wait(max time.Duration) {
+	n.lock.Lock()
+	period := resyncPeriod
+	if n.polling {
+		period = pollPeriod
+	}
+	n.lock.Unlock()
+
+	if max < period {
+		period = max
+	}
+	if period <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(period)
+	defer timer.Stop()
+
+	select {
+	case <-n.changed:
+	case <-timer.C:
+	}
+}
+
+// err returns the error for the first observed object which matched an error assertion, if any.
+func (n *changeNotifier) err() error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.violation == nil {
+		return nil
+	}
+	return n.violation
+}
+
+// stop stops all watches of the notifier.
+func (n *changeNotifier) stop() {
+	n.cancel()
+	n.abort()
+}
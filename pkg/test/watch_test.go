@@ -0,0 +1,268 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	harness "github.com/kyverno/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/kyverno/kuttl/pkg/test/utils"
+)
+
+// noWatchClient hides the Watch method of the wrapped client.
+type noWatchClient struct {
+	client.Client
+}
+
+func TestWatchChanges(t *testing.T) {
+	for _, test := range []struct {
+		testName string
+		watch    bool
+		polling  bool
+	}{
+		{testName: "watch", watch: true},
+		{testName: "fallback to polling", polling: true},
+	} {
+		test := test
+
+		t.Run(test.testName, func(t *testing.T) {
+			var cl client.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+			if !test.watch {
+				cl = noWatchClient{cl}
+			}
+
+			step := Step{
+				Asserts: []asserts{
+					{object: testutils.NewPod("hello", "")},
+				},
+				Client:          func(bool) (client.Client, error) { return cl, nil },
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+				Logger:          testutils.NewTestLogger(t, ""),
+			}
+
+			notifier := step.watchChanges(testNamespace)
+			defer notifier.stop()
+
+			assert.Eventually(t, func() bool {
+				notifier.lock.Lock()
+				defer notifier.lock.Unlock()
+				return notifier.polling == test.polling
+			}, time.Second, 10*time.Millisecond)
+
+			// give the watch time to be established
+			time.Sleep(100 * time.Millisecond)
+
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				assert.Nil(t, cl.Create(context.TODO(), testutils.NewPod("hello", testNamespace)))
+			}()
+
+			start := time.Now()
+			notifier.wait(resyncPeriod)
+			elapsed := time.Since(start)
+
+			if test.polling {
+				assert.InDelta(t, pollPeriod.Seconds(), elapsed.Seconds(), 0.5)
+			} else {
+				assert.Less(t, elapsed, pollPeriod)
+			}
+		})
+	}
+}
+
+func TestRunErrorsObservedBetweenChecks(t *testing.T) {
+	cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+	step := Step{
+		Apply: []apply{
+			{object: testutils.NewPod("hello", "")},
+		},
+		Asserts: []asserts{
+			{object: testutils.WithStatus(t, testutils.NewPod("hello", ""), map[string]interface{}{
+				"phase": "Ready",
+			})},
+		},
+		Errors: []asserts{
+			{object: testutils.WithStatus(t, testutils.NewPod("hello", ""), map[string]interface{}{
+				"phase": "Failed",
+			})},
+		},
+		Assert: &harness.TestAssert{
+			Timeout: 10,
+		},
+		Client:          func(bool) (client.Client, error) { return cl, nil },
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+		Logger:          testutils.NewTestLogger(t, ""),
+	}
+
+	go func() {
+		time.Sleep(time.Second)
+
+		// the pod fails only for a moment, the state is not seen by any check
+		for _, phase := range []string{"Failed", "Pending"} {
+			pod := testutils.NewPod("hello", testNamespace)
+			assert.Nil(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: "hello"}, pod))
+			assert.Nil(t, cl.Update(context.TODO(), testutils.WithStatus(t, pod, map[string]interface{}{
+				"phase": phase,
+			})))
+		}
+	}()
+
+	start := time.Now()
+	errors := step.Run(t, testNamespace)
+
+	assert.Less(t, time.Since(start), 5*time.Second)
+	if assert.Len(t, errors, 2) {
+		assert.Contains(t, errors[0].Error(), "phase: Failed")
+		assert.EqualError(t, errors[1], "resource /v1, Kind=Pod hello matched error assertion")
+	}
+}
+
+func TestRunErrorsClearUp(t *testing.T) {
+	disabled := false
+
+	for _, test := range []struct {
+		name      string
+		invariant *bool
+	}{
+		{name: "errors re-checked until the timeout", invariant: &disabled},
+		// the pod matched the error assertion before the step started, it is not a violation until it changes
+		{name: "invariant errors ignore existing objects"},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			failed := testutils.WithStatus(t, testutils.NewPod("hello", testNamespace), map[string]interface{}{
+				"phase": "Failed",
+			})
+			cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(failed).Build()
+
+			step := Step{
+				Errors: []asserts{
+					{object: testutils.WithStatus(t, testutils.NewPod("hello", ""), map[string]interface{}{
+						"phase": "Failed",
+					})},
+				},
+				Assert: &harness.TestAssert{
+					Timeout:         10,
+					ErrorsInvariant: test.invariant,
+				},
+				Client:          func(bool) (client.Client, error) { return cl, nil },
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+				Logger:          testutils.NewTestLogger(t, ""),
+			}
+
+			go func() {
+				time.Sleep(time.Second)
+				assert.Nil(t, cl.Delete(context.TODO(), testutils.NewPod("hello", testNamespace)))
+			}()
+
+			assert.Empty(t, step.Run(t, testNamespace))
+		})
+	}
+}
+
+func TestRunErrorsInterruptWaitForReady(t *testing.T) {
+	readyPollInterval = 10 * time.Millisecond
+	enabled := true
+
+	cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+	step := Step{
+		Apply: []apply{
+			{object: testutils.NewPod("hello", ""), waitForReady: &enabled},
+		},
+		Errors: []asserts{
+			{object: testutils.WithStatus(t, testutils.NewPod("hello", ""), map[string]interface{}{
+				"phase": "Failed",
+			})},
+		},
+		Timeout:         10,
+		Client:          func(bool) (client.Client, error) { return cl, nil },
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+		Logger:          testutils.NewTestLogger(t, ""),
+	}
+
+	go func() {
+		time.Sleep(time.Second)
+
+		pod := testutils.NewPod("hello", testNamespace)
+		assert.Nil(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: "hello"}, pod))
+		assert.Nil(t, cl.Update(context.TODO(), testutils.WithStatus(t, pod, map[string]interface{}{
+			"phase": "Failed",
+		})))
+	}()
+
+	start := time.Now()
+	errors := step.Run(t, testNamespace)
+
+	// the pod never becomes ready, the step fails on the violation instead of the timeout of the step
+	assert.Less(t, time.Since(start), 5*time.Second)
+	if assert.Len(t, errors, 2) {
+		assert.EqualError(t, errors[1], "resource /v1, Kind=Pod hello matched error assertion")
+	}
+}
+
+// resumingClient serves the watches of the wrapped client from fake watchers and records their resource versions.
+type resumingClient struct {
+	client.WithWatch
+	watchers         chan *watch.FakeWatcher
+	resourceVersions chan string
+}
+
+func (c *resumingClient) Watch(_ context.Context, _ client.ObjectList, opts ...client.ListOption) (watch.Interface, error) {
+	options := (&client.ListOptions{}).ApplyOptions(opts)
+	w := watch.NewFakeWithChanSize(10, false)
+	c.resourceVersions <- options.AsListOptions().ResourceVersion
+	c.watchers <- w
+	return w, nil
+}
+
+func TestWatchResume(t *testing.T) {
+	cl := &resumingClient{
+		WithWatch:        fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(),
+		watchers:         make(chan *watch.FakeWatcher, 1),
+		resourceVersions: make(chan string, 1),
+	}
+
+	step := Step{
+		Asserts: []asserts{
+			{object: testutils.NewPod("hello", "")},
+		},
+		Client:          func(bool) (client.Client, error) { return cl, nil },
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+		Logger:          testutils.NewTestLogger(t, ""),
+	}
+
+	notifier := step.watchChanges(testNamespace)
+	defer notifier.stop()
+
+	// the first watch starts at the most recent resource version
+	assert.Equal(t, "", <-cl.resourceVersions)
+	w := <-cl.watchers
+
+	pod := testutils.NewPod("hello", testNamespace)
+	pod.SetResourceVersion("5")
+	w.Modify(pod)
+	w.Stop()
+
+	// a closed watch is resumed from the last seen resource version
+	assert.Equal(t, "5", <-cl.resourceVersions)
+	w = <-cl.watchers
+
+	w.Error(&metav1.Status{Status: metav1.StatusFailure, Code: http.StatusGone, Reason: metav1.StatusReasonExpired})
+	w.Stop()
+
+	// an expired resource version restarts the watch with the current state of the objects
+	assert.Equal(t, "", <-cl.resourceVersions)
+}